@@ -7,20 +7,74 @@ type Argument struct {
 
 	// When the argument is parsed from the input, the argument
 	// text must match the label in order to invoke the command.
-	Label string `yaml:"label"`
+	Label string `yaml:"label" json:"label"`
 
 	// If applicable, any options that are required for the
 	// command.
-	Options []Option `yaml:"options"`
+	Options []Option `yaml:"options,omitempty" json:"options,omitempty"`
+
+	// (optional) groups of option labels, where at most one of
+	// the options in each group can be set, e.g. the options for
+	// (--on) and (--off).
+	ExclusiveGroups [][]string `yaml:"exclusiveGroups,omitempty" json:"exclusiveGroups,omitempty"`
 
 	// The function performed when this command is invoked.
 	// The options will be passed to this function as Flags.
-	ExecFunc   string `yaml:"execFunc"`
+	ExecFunc   string `yaml:"execFunc,omitempty" json:"execFunc,omitempty"`
 	executable func(Flags) []byte
 
 	// (optional) help message for this argument.
-	HelpMsg string `yaml:"help"`
+	HelpMsg string `yaml:"help,omitempty" json:"help,omitempty"`
+
+	// (optional) child arguments which follow the label of this
+	// argument in the input, e.g. "add" in "remote add". If the
+	// argument has child arguments, one of the child arguments is
+	// invoked instead of this argument, so this argument cannot
+	// have any options or an ExecFunc.
+	Arguments []Argument `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+
+	// (optional) if true, the remaining input after the argument
+	// label is passed to the executable verbatim, available from
+	// Flags.Raw, instead of being parsed into options.
+	Raw bool `yaml:"raw,omitempty" json:"raw,omitempty"`
+
+	// (optional) if true, any text after the argument label which
+	// isn't an option or the variable of an option is permitted,
+	// available from Flags.Args, e.g. "some free text" in the input
+	// (echo some free text).
+	FreeForm bool `yaml:"freeForm,omitempty" json:"freeForm,omitempty"`
+
+	// (optional) if true, this argument is invoked when the input
+	// doesn't match the label of any other argument, receiving the
+	// unmatched input, available from Flags.Raw. The label is still
+	// matched as usual. At most one argument can be the default.
+	Default bool `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// The text of the input which matched the label of this argument.
+	matched string
+
+	// The input routed to this argument when it is the default
+	// argument and no argument label was matched.
+	unmatched string
 
 	// This function returns a help message for this argument.
 	help func(Flags) []byte
+
+	// The labels of the parent arguments followed by the label
+	// of this argument, e.g. "remote add".
+	path string
+}
+
+// walkArguments calls the function for each of the arguments and,
+// recursively, their child arguments, stopping at the first error.
+func walkArguments(arguments []Argument, fn func(argument *Argument) error) error {
+	for i := range arguments {
+		if err := fn(&arguments[i]); err != nil {
+			return err
+		}
+		if err := walkArguments(arguments[i].Arguments, fn); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,416 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const rawConfig = `
+commands:
+  - label: run
+    arguments:
+      - label: ""
+        execFunc: Run
+        raw: true
+  - label: exec
+    arguments:
+      - label: sh
+        execFunc: Shell
+        raw: true
+      - label: list
+        execFunc: List
+`
+
+func TestRawArgument(t *testing.T) {
+	raw := func(name string) func(Flags) []byte {
+		return func(flags Flags) []byte { return []byte(name + " " + flags.Raw() + "\n") }
+	}
+	app, _ := newTestApp(t, rawConfig, map[string]func(Flags) []byte{"Run": raw("run"), "Shell": raw("sh"), "List": raw("list")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"run ls -la /tmp", "run ls -la /tmp\n"},
+		{`run echo "a  b" --c=d`, "run echo \"a  b\" --c=d\n"},
+		{"run echo it's", "run echo it's\n"},
+		{"exec sh echo it's", "sh echo it's\n"},
+		{"exec sh echo list", "sh echo list\n"},
+		{"exec sh -- ls", "sh -- ls\n"},
+		{"exec list", "list \n"},
+		{"exec list it's", "unterminated quote (') detected\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const ambiguousConfig = `
+commands:
+  - label: show
+    arguments:
+      - label: tasks
+        execFunc: Tasks
+      - label: daily tasks
+        execFunc: DailyTasks
+      - label: users
+        execFunc: Users
+`
+
+func TestAmbiguousArguments(t *testing.T) {
+	funcs := map[string]func(Flags) []byte{"Tasks": output("tasks\n"), "DailyTasks": output("daily tasks\n"), "Users": output("users\n")}
+	app, _ := newTestApp(t, ambiguousConfig, funcs, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"show tasks", "tasks\n"},
+		{"show daily tasks", "daily tasks\n"},
+		{"show users tasks", "ambiguous use of the \"show\" command, multiple arguments provided \"tasks\", \"users\"\n"},
+		{"show daily tasks users", "ambiguous use of the \"show\" command, multiple arguments provided \"daily tasks\", \"users\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const boundariesConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: All
+        options:
+          - label: tag
+            long: --tag
+            variable:
+              label: tag
+      - label: ""
+        execFunc: Default
+        options:
+          - label: tag
+            long: --tag
+            variable:
+              label: tag
+`
+
+func TestArgumentBoundaries(t *testing.T) {
+	app, _ := newTestApp(t, boundariesConfig, map[string]func(Flags) []byte{"All": echo("all"), "Default": echo("default")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"get --tag=allowed", "default {tag: set=true value=\"allowed\"}\n"},
+		{"get --tag=all", "default {tag: set=true value=\"all\"}\n"},
+		{"get all --tag=allowed", "all {tag: set=true value=\"allowed\"}\n"},
+		{"get --tag=small all", "all {tag: set=true value=\"small\"}\n"},
+		{"get allowed", "invalid text \"allowed\" detected\n"},
+		{"get ball", "invalid text \"ball\" detected\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const nestedConfig = `
+commands:
+  - label: remote
+    help: Manage remotes
+    arguments:
+      - label: add
+        help: Add a remote
+        arguments:
+          - label: origin
+            help: Add the origin
+            execFunc: Origin
+            options:
+              - label: url
+                short: -u
+                variable:
+                  label: url
+          - label: upstream
+            execFunc: Upstream
+      - label: list
+        execFunc: List
+`
+
+func TestNestedArguments(t *testing.T) {
+	funcs := map[string]func(Flags) []byte{"Origin": echo("origin"), "Upstream": echo("upstream"), "List": echo("list")}
+	app, _ := newTestApp(t, nestedConfig, funcs, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"remote add origin", "origin {url: set=false value=\"\"}\n"},
+		{"remote add origin -u git@host:repo", "origin {url: set=true value=\"git@host:repo\"}\n"},
+		{"remote add upstream", "upstream {}\n"},
+		{"remote list", "list {}\n"},
+		{"remote add", "invalid use of the \"remote add\" command, no valid argument provided\n"},
+		{"remote origin", "invalid use of the \"remote\" command, no valid argument provided\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNestedArgumentsHelp(t *testing.T) {
+	app, _ := newTestApp(t, nestedConfig, map[string]func(Flags) []byte{"Origin": output(""), "Upstream": output(""), "List": output("")}, "")
+	tests := []struct {
+		input   string
+		want    []string
+		notWant []string
+	}{
+		{"remote help", []string{"Usage: remote\n", "remote add|list\n", "\tadd  Add a remote\n", "remote add origin|upstream\n", "\torigin   Add the origin\n"}, nil},
+		{"remote add help", []string{"Usage: remote add\n", "remote add origin|upstream\n", "\torigin   Add the origin\n"}, []string{"list"}},
+		{"remote add origin help", []string{"Usage: remote add origin\n", "remote add origin -u\n"}, []string{"upstream", "list"}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			help := string(app.Execute(test.input))
+			for _, want := range test.want {
+				if !strings.Contains(help, want) {
+					t.Errorf("Execute(%q) = %q, want it to contain %q", test.input, help, want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(help, notWant) {
+					t.Errorf("Execute(%q) = %q, want it not to contain %q", test.input, help, notWant)
+				}
+			}
+			if err := app.Check(test.input); err != nil {
+				t.Errorf("Check(%q) = %v, want the help Execute writes to be valid", test.input, err)
+			}
+		})
+	}
+	for input, want := range map[string]string{
+		"remote other help":            `invalid use of the "remote" command, no valid argument provided`,
+		"remote add origin other help": "",
+	} {
+		err := app.Check(input)
+		if (err == nil && want != "") || (err != nil && err.Error() != want) {
+			t.Errorf("Check(%q) = %v, want %q", input, err, want)
+		}
+		if wantHelp := want == ""; strings.Contains(string(app.Execute(input)), "Usage: ") != wantHelp {
+			t.Errorf("Execute(%q) = %q, want help %t", input, app.Execute(input), wantHelp)
+		}
+	}
+}
+
+const optionsFirstConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: All
+        options:
+          - label: verbose
+            short: -v
+          - label: name
+            short: -n
+            long: --name
+            variable:
+              label: name
+      - label: one
+        execFunc: One
+        options:
+          - label: name
+            short: -n
+            variable:
+              label: name
+`
+
+func TestOptionsBeforeArgument(t *testing.T) {
+	app, _ := newTestApp(t, optionsFirstConfig, map[string]func(Flags) []byte{"All": echo("all"), "One": echo("one")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"get all -v", "all {name: set=false value=\"\", verbose: set=true}\n"},
+		{"get -v all", "all {name: set=false value=\"\", verbose: set=true}\n"},
+		{"get -v all -n x", "all {name: set=true value=\"x\", verbose: set=true}\n"},
+		{"get -n x all -v", "all {name: set=true value=\"x\", verbose: set=true}\n"},
+		{"get --name=x all", "all {name: set=true value=\"x\", verbose: set=false}\n"},
+		{"get -n one all", "all {name: set=true value=\"one\", verbose: set=false}\n"},
+		{"get -n all one", "one {name: set=true value=\"all\"}\n"},
+		{"get -n all", "invalid use of the \"get\" command, no valid argument provided\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const defaultArgumentConfig = `
+commands:
+  - label: open
+    arguments:
+      - label: file
+        execFunc: File
+      - label: url
+        execFunc: URL
+        default: true
+        options:
+          - label: new
+            short: -n
+  - label: close
+    arguments:
+      - label: file
+        execFunc: Close
+`
+
+func TestDefaultArgument(t *testing.T) {
+	record := func(name string) func(Flags) []byte {
+		return func(flags Flags) []byte {
+			return []byte(fmt.Sprintf("%s %q %t\n", name, flags.Raw(), flags.IsSet("new")))
+		}
+	}
+	app, _ := newTestApp(t, defaultArgumentConfig, map[string]func(Flags) []byte{"File": record("file"), "URL": record("url"), "Close": record("close")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"open file", "file \"\" false\n"},
+		{"open url", "url \"\" false\n"},
+		{"open url -n", "url \"\" true\n"},
+		{"open example.com", "url \"example.com\" false\n"},
+		{"open example.com/a b", "url \"example.com/a b\" false\n"},
+		{"open example.com -n", "url \"example.com\" true\n"},
+		{"open", "url \"\" false\n"},
+		{"close file", "close \"\" false\n"},
+		{"close example.com", "invalid use of the \"close\" command, no valid argument provided\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDefaultArgumentOptionValues(t *testing.T) {
+	config := strings.Replace(defaultArgumentConfig, "            short: -n\n", `            short: -n
+          - label: env
+            long: --env
+            keyValue: true
+            variable:
+              label: pair
+          - label: profile
+            short: -p
+            long: --profile
+            variable:
+              label: profile
+`, 1)
+	record := func(flags Flags) []byte { return []byte(fmt.Sprintf("%q %s\n", flags.Raw(), flags)) }
+	app, _ := newTestApp(t, config, map[string]func(Flags) []byte{"File": output(""), "URL": record, "Close": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"open --env A=B", `"" {env: set=true value="A=B", new: set=false, profile: set=false value=""}` + "\n"},
+		{"open example.com --env A=B", `"example.com" {env: set=true value="A=B", new: set=false, profile: set=false value=""}` + "\n"},
+		{"open --env=A=B example.com", `"example.com" {env: set=true value="A=B", new: set=false, profile: set=false value=""}` + "\n"},
+		{"open -p work example.com", `"example.com" {env: set=false value="", new: set=false, profile: set=true value="work"}` + "\n"},
+		{"open --profile=work example.com", `"example.com" {env: set=false value="", new: set=false, profile: set=true value="work"}` + "\n"},
+		{"open --env A=B -n", `"" {env: set=true value="A=B", new: set=true, profile: set=false value=""}` + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDefaultArgumentInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		want      string
+	}{
+		{"multiple", "      - label: a\n        default: true\n      - label: b\n        default: true\n", `multiple default arguments "a" and "b" detected`},
+		{"with an empty label", "      - label: a\n        default: true\n      - label: \"\"\n", `default argument "a" cannot be used with an empty argument label`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(testHeader + "commands:\n  - label: open\n    arguments:\n" + test.arguments))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+const freeFormConfig = `
+caseInsensitive: true
+commands:
+  - label: echo
+    arguments:
+      - label: ""
+        execFunc: Echo
+        freeForm: true
+        options:
+          - label: upper
+            short: -u
+      - label: daily tasks
+        execFunc: Tasks
+        freeForm: true
+        options:
+          - label: limit
+            short: -l
+            variable:
+              label: limit
+      - label: now
+        execFunc: Now
+`
+
+func TestFreeFormArgs(t *testing.T) {
+	record := func(name string) func(Flags) []byte {
+		return func(flags Flags) []byte {
+			return []byte(fmt.Sprintf("%s positional=%q args=%q\n", name, flags.Positional(), flags.Args()))
+		}
+	}
+	app, _ := newTestApp(t, freeFormConfig, map[string]func(Flags) []byte{"Echo": record("echo"), "Tasks": record("tasks"), "Now": record("now")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"echo some free text", "echo positional=\"\" args=[\"some\" \"free\" \"text\"]\n"},
+		{"echo -u some free text", "echo positional=\"\" args=[\"some\" \"free\" \"text\"]\n"},
+		{"echo some -u \"free text\"", "echo positional=\"\" args=[\"some\" \"free text\"]\n"},
+		{"echo", "echo positional=\"\" args=[]\n"},
+		{"echo Daily Tasks for today", "tasks positional=\"Daily Tasks\" args=[\"for\" \"today\"]\n"},
+		{"echo daily tasks -l 5 for today", "tasks positional=\"daily tasks\" args=[\"for\" \"today\"]\n"},
+		{"echo now", "now positional=\"now\" args=[]\n"},
+		{"echo now and later", "invalid text \"and\" detected\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
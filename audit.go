@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// secretMask replaces the values of secret options wherever they are shown
+const secretMask = "***"
+
+// auditRecord is the record of an executed command written to the audit
+// writer, as a single line of JSON. The flags map the label of each option
+// set in the input to its value, which is true for an option without a
+// variable, false for a negated option, the values of every occurrence for
+// a repeatable option, or the value of the variable otherwise.
+type auditRecord struct {
+	Time         time.Time              `json:"time"`
+	Command      string                 `json:"command"`
+	Argument     string                 `json:"argument"`
+	Flags        map[string]interface{} `json:"flags"`
+	OutputLength int                    `json:"outputLength"`
+}
+
+// audit writes the record of the executed command to the audit writer, if any
+func (app *App) audit(command Command, argument Argument, flags Flags, output []byte) error {
+	if app.currentConfig().AuditWriter == nil {
+		return nil
+	}
+	record, err := json.Marshal(auditRecord{
+		Time:         time.Now(),
+		Command:      command.Label,
+		Argument:     argument.path,
+		Flags:        flags.auditValues(),
+		OutputLength: len(output),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = app.currentConfig().AuditWriter.Write(append(record, '\n'))
+	return err
+}
+
+// auditValues returns the values of the options which have been set or
+// negated, keyed by label, with the values of any secret options masked.
+func (flags Flags) auditValues() map[string]interface{} {
+	values := make(map[string]interface{})
+	for label, meta := range flags.mapping {
+		switch {
+		case !meta.isset && !meta.negated:
+			continue
+		case meta.option.Secret && meta.hasVar:
+			values[label] = secretMask
+		case meta.negated:
+			values[label] = false
+		case !meta.hasVar:
+			values[label] = true
+		case meta.option.Repeatable:
+			values[label] = meta.values()
+		default:
+			values[label] = meta.variable
+		}
+	}
+	return values
+}
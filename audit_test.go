@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const auditConfig = `
+commands:
+  - label: deploy
+    arguments:
+      - label: app
+        execFunc: App
+        options:
+          - label: quiet
+            short: -q
+          - label: color
+            long: --color
+          - label: name
+            short: -n
+            variable:
+              label: name
+          - label: token
+            short: -t
+            secret: true
+            variable:
+              label: token
+          - label: tag
+            long: --tag
+            repeatable: true
+            variable:
+              label: tag
+`
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		input string
+		flags map[string]interface{}
+	}{
+		{"deploy app", map[string]interface{}{}},
+		{"deploy app -q --no-color -n web", map[string]interface{}{"quiet": true, "color": false, "name": "web"}},
+		{"deploy app -t hunter2", map[string]interface{}{"token": secretMask}},
+		{"deploy app --tag=a --tag=b", map[string]interface{}{"tag": []interface{}{"a", "b"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			app, _ := newTestApp(t, auditConfig, map[string]func(Flags) []byte{"App": output("deployed\n")}, "")
+			audit := new(bytes.Buffer)
+			app.currentConfig().AuditWriter = audit
+			before := time.Now()
+			app.Execute(test.input)
+			if strings.Contains(audit.String(), "hunter2") {
+				t.Errorf("audit record %q does not mask the secret", audit)
+			}
+			if strings.Count(audit.String(), "\n") != 1 {
+				t.Fatalf("expected a single line audit record, got %q", audit)
+			}
+			var record auditRecord
+			if err := json.Unmarshal(audit.Bytes(), &record); err != nil {
+				t.Fatal(err)
+			}
+			if record.Command != "deploy" || record.Argument != "app" || record.OutputLength != len("deployed\n") {
+				t.Errorf("audit record = %+v, want the command, argument and output length", record)
+			}
+			if record.Time.Before(before) || record.Time.After(time.Now()) {
+				t.Errorf("audit record time = %s, want the time the command ran", record.Time)
+			}
+			if !reflect.DeepEqual(record.Flags, test.flags) {
+				t.Errorf("audit record flags = %v, want %v", record.Flags, test.flags)
+			}
+		})
+	}
+}
+
+func TestAuditNotExecuted(t *testing.T) {
+	app, _ := newTestApp(t, auditConfig, map[string]func(Flags) []byte{"App": output("")}, "")
+	audit := new(bytes.Buffer)
+	app.currentConfig().AuditWriter = audit
+	for _, input := range []string{"", "deploy", "deploy none", "deploy app text", "help", "deploy help", "unknown"} {
+		app.Execute(input)
+		if audit.Len() != 0 {
+			t.Errorf("Execute(%q) wrote the audit record %q, want no record", input, audit)
+			audit.Reset()
+		}
+	}
+}
+
+func TestAuditWriterError(t *testing.T) {
+	app, _ := newTestApp(t, auditConfig, map[string]func(Flags) []byte{"App": output("deployed\n")}, "")
+	app.currentConfig().AuditWriter = &pipeWriter{err: io.ErrShortWrite}
+	stderr := new(bytes.Buffer)
+	if got := string(app.WithErrWriter(stderr).Execute("deploy app")); got != "deployed\n" {
+		t.Errorf("Execute() = %q, want the output despite the audit error", got)
+	}
+	if want := "audit: " + io.ErrShortWrite.Error() + "\n"; stderr.String() != want {
+		t.Errorf("Execute() wrote %q to the error stream, want %q", stderr, want)
+	}
+}
@@ -2,73 +2,318 @@ package cli
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
 var whitespaceCharacters = " \n\r\t"
 
+// continuationPrompt is the prompt for a line continuing the previous line
+const continuationPrompt = "> "
+
+// errVetoed is returned when the input middleware vetoes the input
+var errVetoed = errors.New("input vetoed by the input middleware")
+
 // App is the CLI application
 type App struct {
-	config *Config
-	writer *bufio.Writer
-	reader *bufio.Reader
-	sigint chan os.Signal
-	active bool
+	config    atomic.Pointer[Config]
+	program   interface{}
+	in        io.Reader
+	out       io.Writer
+	writer    *bufio.Writer
+	errWriter *bufio.Writer
+	reader    *bufio.Reader
+	sigint    chan os.Signal
+	signals   bool
+	active    atomic.Bool
+	state     *State
+	prompt    string
+	sticky    map[stickyKey]flagMetadata
+
+	middleware []Middleware
+
+	cancelMutex sync.Mutex
+	cancel      context.CancelFunc
+
+	quitMutex sync.Mutex
+	quit      chan struct{}
+
+	writeMutex sync.Mutex
+
+	lastErrMutex sync.Mutex
+	lastErr      error
+
+	historyMutex sync.Mutex
+	history      []string
+	discarded    int
+
+	configMutex sync.Mutex
+	reloaded    *Config
 }
 
-// New creates a new App from the given config
+// New creates a new App from the given config, reading
+// from stdin and writing to stdout.
 func New(config *Config) (app *App) {
-	return &App{
-		config: config,
-		writer: bufio.NewWriter(os.Stdout),
-		reader: bufio.NewReader(os.Stdin),
-		sigint: make(chan os.Signal, 1),
-		active: true,
+	app = NewWithIO(config, os.Stdin, os.Stdout)
+	app.signals = true
+	return app
+}
+
+// NewWithIO creates a new App from the given config, reading from in
+// and writing to out, e.g. to embed the CLI over a network connection.
+// Unlike New, the App doesn't handle any OS signals, so Run returns
+// only once the user exits or the input ends.
+func NewWithIO(config *Config, in io.Reader, out io.Writer) (app *App) {
+	app = &App{
+		in:        in,
+		out:       out,
+		writer:    bufio.NewWriter(out),
+		errWriter: bufio.NewWriter(os.Stderr),
+		reader:    bufio.NewReader(in),
+		sigint:    make(chan os.Signal, 1),
+		state:     newState(),
+		sticky:    make(map[stickyKey]flagMetadata),
+		quit:      make(chan struct{}),
 	}
+	app.config.Store(config)
+	app.active.Store(true)
+	return app
+}
+
+// WithErrWriter sets the writer for the error stream of the App, which
+// is stderr by default. Errors returned by executables and the flags
+// dumped by DebugFlags are written to the error stream.
+func (app *App) WithErrWriter(w io.Writer) *App {
+	app.errWriter = bufio.NewWriter(w)
+	return app
+}
+
+// LastError returns the error returned by the executable of the last
+// command executed, or nil if it didn't return an error. Only executables
+// of the type func(Flags) ([]byte, error) can return an error.
+func (app *App) LastError() error {
+	app.lastErrMutex.Lock()
+	defer app.lastErrMutex.Unlock()
+	return app.lastErr
+}
+
+// setLastError sets the error returned by LastError
+func (app *App) setLastError(err error) {
+	app.lastErrMutex.Lock()
+	defer app.lastErrMutex.Unlock()
+	app.lastErr = err
 }
 
 // Using gets the App to use the methods from program
 func (app *App) Using(program interface{}) (*App, error) {
-	if err := app.config.withProgram(program); err != nil {
+	app.program = program
+	if err := app.currentConfig().withProgram(program); err != nil {
 		return app, err
 	}
 	return app, nil
 }
 
+// Verify checks that every execFunc in the config maps to a method of
+// the program passed to Using. This is useful with LazyExec, where the
+// methods are otherwise only looked up when they are first invoked.
+func (app *App) Verify() error {
+	return app.currentConfig().verify(app.program)
+}
+
+// State returns the State of the CLI session, shared with
+// every executable through Flags.State.
+func (app *App) State() *State {
+	return app.state
+}
+
+// Reset resets the CLI session, so that the App can be reused for a new
+// session without being torn down, e.g. by a server reusing an App across
+// client sessions. The values remembered for sticky options and the values
+// stored in the State are cleared, then the init function is re-run and its
+// output written. The config, the methods from the program, any middleware
+// and the history are preserved.
+func (app *App) Reset() error {
+	app.clearSticky()
+	app.state.clear()
+	app.active.Store(true)
+	app.quitMutex.Lock()
+	app.quit = make(chan struct{})
+	app.quitMutex.Unlock()
+	return app.write(app.currentConfig().init(Flags{state: app.state}))
+}
+
+// Reload replaces the config of the App at runtime, e.g. so that a long
+// running CLI picks up changes to its config without restarting. The config
+// is validated, unless it was loaded with one of the LoadConfig functions,
+// and the methods from the program passed to Using are applied to it. The
+// config is swapped in before the next input is handled, so a command being
+// executed never sees a partly replaced config. If the config is invalid, an
+// error is returned and the current config is kept.
+func (app *App) Reload(config *Config) error {
+	if config.help == nil {
+		if err := config.setup(); err != nil {
+			return err
+		}
+	}
+	if app.program != nil {
+		if err := config.withProgram(app.program); err != nil {
+			return err
+		}
+	}
+	app.configMutex.Lock()
+	defer app.configMutex.Unlock()
+	app.reloaded = config
+	return nil
+}
+
+// swapConfig swaps in the config passed to Reload, if there is one, just
+// before an input is handled.
+func (app *App) swapConfig() {
+	app.configMutex.Lock()
+	defer app.configMutex.Unlock()
+	if app.reloaded != nil {
+		app.config.Store(app.reloaded)
+		app.reloaded = nil
+	}
+}
+
+// currentConfig returns the config, which is safe to call on any goroutine
+// while the config is swapped by swapConfig.
+func (app *App) currentConfig() *Config {
+	return app.config.Load()
+}
+
 // Run runs the CLI
 func (app *App) Run() {
+	app.run("")
+}
 
-	// Write CLI initial input
-	initOutput := app.config.init(Flags{})
+// RunArgs runs the input given by the program arguments, e.g. os.Args[1:]
+// for (mytool list all), as soon as the CLI starts. Any argument which is
+// empty or contains a delimiter, quote, backslash or line break is quoted,
+// so that it is a single token of the input. The arguments are run as a
+// single input, even if the first starts with "#". The CLI then exits,
+// unless the config continues interactively after the arguments. If there
+// are no arguments, the CLI is run as usual.
+func (app *App) RunArgs(args []string) {
+	if len(args) == 0 {
+		app.Run()
+		return
+	}
+	input := app.joinArgs(args)
+	if app.currentConfig().InteractiveAfterArgs {
+		app.run(input)
+		return
+	}
+
+	// Write CLI initial output, followed by the output from the input and
+	// from the exit function, unless the input was the exit command
+	output := app.currentConfig().init(Flags{state: app.state})
+	output = append(output, app.getOutput(input)...)
+	if app.active.Load() {
+		app.prepareExit()
+		output = append(output, app.currentConfig().exit(Flags{state: app.state})...)
+	}
+	if err := app.write(output); err != nil {
+		if isClosedPipe(err) {
+			app.closePipe()
+			return
+		}
+		log.Fatal(err)
+	}
+}
+
+// joinArgs joins the program arguments into a single input, quoting any
+// argument which would otherwise not be a single token of the input.
+func (app *App) joinArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, app.currentConfig().delimiters()+"\"'\\\r\n") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, app.currentConfig().separator())
+}
+
+// run runs the CLI, running the initial input, if any, once the
+// initial output has been written.
+func (app *App) run(input string) {
+
+	// Ignore SIGPIPE so writes to a closed pipe return an error
+	// rather than terminating the program.
+	if app.signals {
+		signal.Ignore(syscall.SIGPIPE)
+	}
+
+	// Write CLI initial input, followed by the output from the initial input
+	initOutput := app.currentConfig().init(Flags{state: app.state})
+	if input != "" {
+		initOutput = append(initOutput, app.getOutput(input)...)
+	}
 	if err := app.write([]byte(initOutput)); err != nil {
+		if isClosedPipe(err) {
+			app.closePipe()
+			return
+		}
 		log.Fatal(err)
 	}
 
 	// Run the CLI until terminaled by ctl-C or user exit
 	go func() {
 
-		for app.active {
+		for app.active.Load() {
 
 			// Write CLI prompt
-			if err := app.write([]byte(app.config.Prompt)); err != nil {
+			app.prompt = app.currentConfig().Prompt
+			if app.currentConfig().promptFunc != nil {
+				app.prompt = app.currentConfig().promptFunc()
+			}
+			app.prompt = colorize(app.prompt, ansiGreen, app.color())
+			if err := app.write([]byte(app.prompt)); err != nil {
+				if isClosedPipe(err) {
+					app.closePipe()
+					break
+				}
 				log.Fatal(err)
 			}
 
-			// Get input from CLI
+			// Get input from CLI, discarding it if the CLI was stopped meanwhile
 			input, err := app.read()
-			if err != nil {
+			if errors.Is(err, errInterrupted) || !app.active.Load() {
+				break
+			}
+			eof := errors.Is(err, io.EOF)
+			if err != nil && !eof {
 				log.Fatal(err)
 			}
 
-			// Get output from cli
+			// Get output from cli, with the config passed to Reload, if any
+			app.swapConfig()
 			output := app.getOutput(input)
 
-			// Write output
-			if err := app.write(output); err != nil {
+			// Treat the end of the input like the exit command
+			if eof && app.active.Load() {
+				app.prepareExit()
+				output = append(output, app.currentConfig().exit(Flags{state: app.state})...)
+			}
+
+			// Write output, paging it if enabled
+			if err := app.writePaged(output); err != nil {
+				if isClosedPipe(err) {
+					app.closePipe()
+					break
+				}
 				log.Fatal(err)
 			}
 		}
@@ -76,13 +321,152 @@ func (app *App) Run() {
 		app.sigint <- os.Kill
 	}()
 
-	// Interrupt on ctl-C
-	signal.Notify(app.sigint, os.Interrupt)
-	<-app.sigint
+	// Interrupt on ctl-C, which cancels the command being executed
+	// if there is one, otherwise terminates the CLI.
+	if app.signals {
+		signal.Notify(app.sigint, os.Interrupt)
+	}
+	quit := app.stopped()
+	for {
+		select {
+		case sig := <-app.sigint:
+			if sig == os.Interrupt && app.cancelCommand() {
+				continue
+			}
+
+		// Exit the CLI if it was stopped, cancelling the command
+		// being executed if there is one
+		case <-quit:
+			app.cancelCommand()
+			if app.active.CompareAndSwap(true, false) {
+				if err := app.write(app.currentConfig().exit(Flags{state: app.state})); err != nil && !isClosedPipe(err) {
+					log.Fatal(err)
+				}
+			}
+		}
+		return
+	}
+}
+
+// Stop stops the CLI from another goroutine, without sending an OS signal,
+// e.g. when the CLI is embedded in a larger program. Any command being
+// executed is cancelled, then the exit function is run and its output
+// written, and Run returns. Stop has no effect if the CLI has already
+// been stopped.
+func (app *App) Stop() {
+	app.quitMutex.Lock()
+	defer app.quitMutex.Unlock()
+	select {
+	case <-app.quit:
+	default:
+		close(app.quit)
+	}
+}
+
+// stopped returns the channel which is closed when the CLI is stopped
+func (app *App) stopped() <-chan struct{} {
+	app.quitMutex.Lock()
+	defer app.quitMutex.Unlock()
+	return app.quit
+}
+
+// startCommand returns a new context for the command about to be executed,
+// which is cancelled if the user interrupts the command.
+func (app *App) startCommand() context.Context {
+	app.cancelMutex.Lock()
+	defer app.cancelMutex.Unlock()
+	var ctx context.Context
+	ctx, app.cancel = context.WithCancel(context.Background())
+	return ctx
+}
+
+// finishCommand cancels the context of the command once it has been executed
+func (app *App) finishCommand() {
+	app.cancelMutex.Lock()
+	defer app.cancelMutex.Unlock()
+	if app.cancel != nil {
+		app.cancel()
+		app.cancel = nil
+	}
+}
+
+// fail records the error returned by the executable of the command,
+// writing it to the error stream.
+func (app *App) fail(err error) {
+	app.setLastError(err)
+	if err := app.writeErr([]byte(fmt.Sprintf("%v\n", err))); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// cancelCommand cancels the context of the command being executed,
+// returning whether there was a command being executed.
+func (app *App) cancelCommand() bool {
+	app.cancelMutex.Lock()
+	defer app.cancelMutex.Unlock()
+	if app.cancel == nil {
+		return false
+	}
+	app.cancel()
+	return true
+}
+
+// RunScript runs the CLI non-interactively, executing each line read from
+// the script as an input and writing the outputs, without any prompt. Lines
+// starting with "#" are treated as comments and skipped. The script stops at
+// the exit command or at the end of the script, which is treated like the
+// exit command. An error is returned if the script cannot be read or the
+// output cannot be written.
+func (app *App) RunScript(r io.Reader) error {
+	reader := bufio.NewReader(r)
+
+	// Write CLI initial input
+	if err := app.write(app.currentConfig().init(Flags{state: app.state})); err != nil {
+		return err
+	}
+
+	for app.active.Load() {
+
+		// Get the next line from the script
+		line, err := reader.ReadString('\n')
+		line = normalizeLineEnding(line)
+		eof := errors.Is(err, io.EOF)
+		if err != nil && !eof {
+			return err
+		}
+
+		// Get output from cli, with the config passed to Reload, if any,
+		// skipping any comments
+		app.swapConfig()
+		var output []byte
+		if !strings.HasPrefix(strings.TrimLeft(line, app.currentConfig().lineDelimiters()), "#") {
+			output = app.getOutput(line)
+		}
+
+		// Treat the end of the script like the exit command
+		if eof && app.active.Load() {
+			app.prepareExit()
+			output = append(output, app.currentConfig().exit(Flags{state: app.state})...)
+		}
+
+		// Write output
+		if err := app.write(output); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // write writes bytes to the CLI
 func (app *App) write(b []byte) error {
+	app.writeMutex.Lock()
+	defer app.writeMutex.Unlock()
+
+	// Strip any escape codes if the output is not a terminal
+	if app.currentConfig().StripANSIWhenNotTTY && !isTerminal(app.out) {
+		b = stripANSI(b)
+	}
 
 	// Attempt to write bytes to writer
 	if _, err := app.writer.Write(b); err != nil {
@@ -97,93 +481,484 @@ func (app *App) write(b []byte) error {
 	return nil
 }
 
-// read reads input from the CLI
+// trim returns the input with the line ending and any leading or
+// trailing delimiters removed.
+func (app *App) trim(input string) string {
+	return strings.Trim(strings.TrimRight(input, "\r\n"), app.currentConfig().delimiters())
+}
+
+// isClosedPipe returns whether the error was caused by writing to a pipe
+// which has been closed by the reader, e.g. when the output is piped to head.
+func isClosedPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// closePipe quietly shuts down the CLI after the output pipe has been closed.
+// The exit function is still run, but its output is discarded.
+func (app *App) closePipe() {
+	app.prepareExit()
+	app.currentConfig().exit(Flags{state: app.state})
+}
+
+// writeErr writes bytes to the CLI error stream
+func (app *App) writeErr(b []byte) error {
+
+	// Attempt to write bytes to error writer
+	if _, err := app.errWriter.Write(b); err != nil {
+		return err
+	}
+
+	// Flush error write buffer to display to the screen
+	if err := app.errWriter.Flush(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// read reads input from the CLI, joining any continued lines
 func (app *App) read() (str string, err error) {
+	for {
+		line, err := app.readLine()
+		if err != nil {
+			return str + line, err
+		}
+
+		// A line ending with an unescaped backslash (\) is continued on the
+		// next line, the lines are joined with a separator in place of the backslash
+		trimmed := strings.TrimRight(line, app.currentConfig().lineDelimiters())
+		if !continuesLine(trimmed) {
+			return str + line, nil
+		}
+		str += trimmed[:len(trimmed)-1] + app.currentConfig().separator()
+
+		// Write the prompt for the continued line
+		app.prompt = colorize(continuationPrompt, ansiGreen, app.color())
+		if err := app.write([]byte(app.prompt)); err != nil {
+			return str, err
+		}
+	}
+}
 
-	// Attempt tog et input from user
+// readLine reads a single line of input from the CLI
+func (app *App) readLine() (str string, err error) {
+
+	// Read from the terminal in raw mode if required
+	if app.rawMode() {
+		return app.readRaw()
+	}
+
+	// Attempt tog et input from user, normalising any CRLF line ending
 	str, err = app.reader.ReadString('\n')
-	if err != nil {
-		return str, err
+	return normalizeLineEnding(str), err
+}
+
+// normalizeLineEnding replaces a CRLF (\r\n) line ending with LF (\n), and
+// removes the carriage return (\r) from a final line without a line feed,
+// so that input from Windows is handled exactly like any other input.
+func normalizeLineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return strings.TrimSuffix(line, "\r\n") + "\n"
 	}
+	return strings.TrimSuffix(line, "\r")
+}
 
-	return str, nil
+// continuesLine returns whether the line ends with a backslash (\) which
+// is not itself escaped by a preceding backslash, e.g. (cmd \) but not (cmd \\).
+func continuesLine(line string) bool {
+	var backslashes int
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
 }
 
 // getOutput extracts the command from the input and runs the correct executable
 func (app *App) getOutput(input string) []byte {
 
-	// Trim any whitespace from the input
-	input = strings.Trim(input, whitespaceCharacters)
+	// Trim any delimiters from the input
+	input = app.trim(input)
 	if input == "" {
 		return []byte{}
 	}
 
-	// Exit the CLI if the ExitCmd is the input
-	if input == app.config.ExitCmd {
+	// Expand any reference to the history into the prior input,
+	// echoing the expanded input before its output.
+	expanded, ok, err := app.expandHistory(input)
+	if err != nil {
+		return []byte(fmt.Sprintf("%v\n", err))
+	}
+	if ok {
+		return append([]byte(expanded+"\n"), app.getOutput(expanded)...)
+	}
+	app.record(input)
+
+	// Apply the input middleware, aborting if any vetoes the input
+	if input, ok = app.prepareInput(input); !ok || input == "" {
+		return []byte{}
+	}
+
+	// Exit the CLI if one of the exit commands is the input
+	if app.currentConfig().isExitCmd(input) {
 		app.prepareExit()
-		return app.config.exit(Flags{})
+		return app.currentConfig().exit(Flags{state: app.state})
+	}
+
+	// Forget the sticky values if the ResetCmd is the input
+	if app.currentConfig().ResetCmd != "" && input == app.currentConfig().ResetCmd {
+		app.clearSticky()
+		return []byte{}
+	}
+
+	// Print the version if the VersionCmd is the input
+	if app.currentConfig().VersionCmd != "" && input == app.currentConfig().VersionCmd {
+		return []byte(app.currentConfig().Version + "\n")
 	}
 
 	// If input ends with help coomand, remove help command from input
 	// and return the help output instead.
-	if strings.HasSuffix(input, app.config.HelpCmd) {
-		input = strings.TrimRight(input[:len(input)-len(app.config.HelpCmd)], whitespaceCharacters)
-		return app.getHelpOutput(input)
+	if helpInput, ok := app.trimHelp(input); ok {
+		return app.getHelpOutput(helpInput)
 	}
 
+	// Remove the dry run flag, if given, and pass any input which
+	// doesn't start with a command to the fallback, if any
+	input, dryRun, fallback := app.resolveInput(input)
+	if fallback {
+		if dryRun {
+			return []byte(fmt.Sprintf("dry run: fallback, input \"%s\"\n", input))
+		}
+		return app.runExecutable(app.currentConfig().fallback, fallbackFlags(input))
+	}
+
+	// Parse the command, argument and flags from the input
+	command, path, flags, err := app.parsePath(input)
+	if err != nil {
+		return []byte(fmt.Sprintf("%v\n", err) + app.usageOnError(command, path))
+	}
+	argument := path[len(path)-1]
+
+	// For a dry run, print what the input resolves to instead of running
+	// the executable, without remembering the values of any sticky options
+	if dryRun {
+		return []byte(fmt.Sprintf("dry run: command \"%s\", argument \"%s\", flags %s\n", command.Label, argument.path, flags))
+	}
+	app.rememberSticky(command, argument, flags)
+
+	// If enabled, dump the resolved flags to the error stream
+	if app.currentConfig().DebugFlags {
+		if err := app.writeErr([]byte(flags.debugString())); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Return the output from the executable
+	output := app.runExecutable(app.wrap(argument.executable), flags)
+
+	// If enabled, write the record of the executed command to the audit writer
+	if err := app.audit(command, argument, flags, output); err != nil {
+		if err := app.writeErr([]byte(fmt.Sprintf("audit: %v\n", err))); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return output
+}
+
+// prepareInput applies the input middleware to the input, then trims it,
+// returning false if any of the middleware vetoes the input.
+func (app *App) prepareInput(input string) (string, bool) {
+	for _, middleware := range app.currentConfig().InputMiddleware {
+		var ok bool
+		if input, ok = middleware(input); !ok {
+			return "", false
+		}
+	}
+	return app.trim(input), true
+}
+
+// resolveInput removes the dry run flag from the input, returning the
+// remaining input, whether the flag was found and whether the input is
+// for the fallback function, as it doesn't start with a command.
+func (app *App) resolveInput(input string) (remaining string, dryRun, fallback bool) {
+	remaining, dryRun = app.cutDryRun(input)
+	if app.currentConfig().fallback != nil {
+		_, _, err := app.extractCommand(remaining)
+		fallback = err != nil
+	}
+	return remaining, dryRun, fallback
+}
+
+// fallbackFlags returns the flags for the fallback function, with the
+// input as the positional text.
+func fallbackFlags(input string) Flags {
+	return Flags{mapping: make(map[string]flagMetadata), rawInput: input, positional: input}
+}
+
+// cutDryRun removes the dry run flag from the input, if the config has one,
+// returning the remaining input and whether the flag was found. The flag is
+// not found after the terminator (--), where the input is passed through.
+func (app *App) cutDryRun(input string) (string, bool) {
+	if app.currentConfig().DryRunFlag == "" {
+		return input, false
+	}
+	spans, err := tokenizeSpans(input, app.currentConfig().delimiters())
+	if err != nil {
+		return input, false
+	}
+	for _, span := range spans {
+		token := input[span.start:span.end]
+		if token == "--" {
+			break
+		}
+		if token == app.currentConfig().DryRunFlag {
+			_, rest := cutSpans(input, []tokenSpan{span}, app.currentConfig().separator())
+			return app.trim(rest), true
+		}
+	}
+	return input, false
+}
+
+// runExecutable runs the executable with the flags, returning its output
+// after any partial line which was streamed but not yet written.
+func (app *App) runExecutable(executable func(Flags) []byte, flags Flags) []byte {
+	stream := newLineWriter(app)
+	flags.state = app.state
+	flags.stream = stream
+	flags.ctx = app.startCommand()
+	flags.fail = app.fail
+	flags.session = &Session{app: app}
+	defer app.finishCommand()
+	app.setLastError(nil)
+	output := executable(flags)
+	return append(stream.remaining(), output...)
+}
+
+// Execute runs a single input and returns its output, without reading from
+// the input or starting the interactive loop. The input is handled exactly
+// as it would be in the interactive loop, including the help and exit
+// commands, e.g. for testing the methods of a program.
+func (app *App) Execute(input string) []byte {
+	app.swapConfig()
+	return app.getOutput(input)
+}
+
+// Parse extracts the command, argument and flags from the input, without
+// running any executable, e.g. to inspect how an input is parsed for a dry
+// run or diagnostics. The input is prepared as it would be by Execute, with
+// the input middleware applied and any dry run flag removed. The results of
+// each stage are returned along with the first error encountered at any
+// stage. The built in commands, such as the exit and help commands, are not
+// commands of the config so cannot be parsed. For input passed to the
+// fallback function, an empty command and argument are returned, along with
+// the flags the fallback function would be given.
+func (app *App) Parse(input string) (Command, Argument, Flags, error) {
+	input, ok := app.prepareInput(app.trim(input))
+	if !ok {
+		return Command{}, Argument{}, Flags{}, errVetoed
+	}
+	input, _, fallback := app.resolveInput(input)
+	if fallback {
+		return Command{}, Argument{}, fallbackFlags(input), nil
+	}
+	return app.parse(input)
+}
+
+// Check runs the full parse pipeline on the input and returns the first
+// error found, without running any executable. This allows the validity
+// of an input to be checked as it is being typed. The input is prepared
+// as it would be by Execute, with any dry run flag removed. The exit command
+// is always valid, the help command is valid if the command and argument it
+// describes exist, and any input for the fallback function is valid.
+func (app *App) Check(input string) error {
+
+	// Prepare the input as it would be by Execute. Input vetoed by the
+	// input middleware is valid, as it is simply ignored.
+	input, ok := app.prepareInput(app.trim(input))
+	if !ok || input == "" || app.currentConfig().isExitCmd(input) || (app.currentConfig().ResetCmd != "" && input == app.currentConfig().ResetCmd) {
+		return nil
+	}
+	if app.currentConfig().VersionCmd != "" && input == app.currentConfig().VersionCmd {
+		return nil
+	}
+
+	// Check the command and argument described by the help command
+	if helpInput, ok := app.trimHelp(input); ok {
+		_, err := app.resolveHelp(helpInput)
+		return err
+	}
+
+	// Any input for the fallback function is valid
+	input, _, fallback := app.resolveInput(input)
+	if fallback {
+		return nil
+	}
+	_, _, _, err := app.parse(input)
+	return err
+}
+
+// trimHelp returns the input with the help command removed and true,
+// if the input ends with one of the help commands as a standalone token
+// which doesn't follow the terminator (--). Otherwise, the input is
+// returned unchanged with false.
+func (app *App) trimHelp(input string) (string, bool) {
+	for _, helpCmd := range app.currentConfig().helpCmds() {
+		if remaining, ok := app.trimHelpCmd(input, helpCmd); ok {
+			return remaining, true
+		}
+	}
+	return input, false
+}
+
+// trimHelpCmd returns the input with the given help command removed and
+// true, if the input ends with the help command as a standalone token
+// which doesn't follow the terminator (--).
+func (app *App) trimHelpCmd(input, helpCmd string) (string, bool) {
+	if input == helpCmd {
+		return "", true
+	}
+	if !strings.HasSuffix(input, helpCmd) {
+		return input, false
+	}
+
+	// The help command must be preceded by a delimiter, so that a
+	// value ending with the help command is not mistaken for it.
+	remaining := input[:len(input)-len(helpCmd)]
+	if !strings.ContainsAny(remaining[len(remaining)-1:], app.currentConfig().delimiters()) {
+		return input, false
+	}
+	for _, field := range app.currentConfig().fields(remaining) {
+		if field == "--" {
+			return input, false
+		}
+	}
+	return strings.TrimRight(remaining, app.currentConfig().delimiters()), true
+}
+
+// parse extracts the command, argument and flags from the input.
+// The first error encountered at any stage is returned.
+func (app *App) parse(input string) (command Command, argument Argument, flags Flags, err error) {
+	command, path, flags, err := app.parsePath(input)
+	if len(path) > 0 {
+		argument = path[len(path)-1]
+	}
+	return command, argument, flags, err
+}
+
+// parsePath extracts the command, the path to the argument and the flags
+// from the input, where the path is the argument extracted at each level,
+// ending with the argument for the input. If an error is encountered
+// extracting the argument, the path ends with the last argument which
+// was extracted, if any. The first error encountered at any stage is
+// returned.
+func (app *App) parsePath(input string) (command Command, path []Argument, flags Flags, err error) {
+
 	// Extract the command and reamining input after removing the input
 	command, remainingInput, err := app.extractCommand(input)
 	if err != nil {
-		return []byte(fmt.Sprintf("%v\n", err))
+		return command, path, flags, err
 	}
 
 	// Get the argument and flags
-	argument, optionsInput, err := app.extractArgument(remainingInput, command)
+	path, optionsInput, err := app.extractArguments(remainingInput, command)
 	if err != nil {
-		return []byte(fmt.Sprintf("%v\n", err))
+		return command, path, flags, err
+	}
+	argument := path[len(path)-1]
+
+	// A raw argument receives the options input verbatim
+	if argument.Raw {
+		flags = Flags{
+			mapping:    make(map[string]flagMetadata),
+			rawInput:   strings.Trim(optionsInput, app.currentConfig().delimiters()),
+			positional: argument.matched,
+		}
+		return command, path, flags, nil
 	}
 
 	// Attempt to extraxt the flags from the options input
-	flags, err := app.extractFlags(optionsInput, argument)
+	flags, err = app.extractFlags(optionsInput, argument)
 	if err != nil {
-		return []byte(fmt.Sprintf("%v\n", err))
+		return command, path, flags, err
 	}
+	flags.rawInput = strings.Trim(argument.unmatched, app.currentConfig().delimiters())
+	flags.positional = argument.matched
 
-	// Return the output from the executable
-	return argument.executable(flags)
+	// The default argument receives the unmatched input as its arguments
+	if argument.unmatched != "" {
+		unmatched, err := tokenize(argument.unmatched, app.currentConfig().delimiters())
+		if err != nil {
+			return command, path, flags, err
+		}
+		flags.args = append(unmatched, flags.args...)
+	}
+	app.restoreSticky(command, argument, flags)
+
+	// Check all the required options have been set
+	if err := checkRequired(argument, flags); err != nil {
+		return command, path, flags, err
+	}
+
+	return command, path, flags, nil
+}
+
+// usageOnError returns the usage line to accompany a parse error, if enabled.
+// The usage is of the last argument of the path which was extracted before
+// the error, otherwise of the command, if it was extracted.
+func (app *App) usageOnError(command Command, path []Argument) string {
+	if !app.currentConfig().UsageOnError || command.Label == "" {
+		return ""
+	}
+	if len(path) == 0 {
+		return command.helpModel().usage() + "\n"
+	}
+	argument := path[len(path)-1]
+	return command.helpModel().usageArg(argument.path, argument.helpModel()) + "\n"
 }
 
 // getHelpOutput extracts the help command output.
 // The input here should be the original input but
 // with the help command removed.
 func (app *App) getHelpOutput(input string) []byte {
+	help, err := app.resolveHelp(input)
+	if err != nil {
+		return []byte(fmt.Sprintf("%v\n", err))
+	}
+	return help(Flags{format: app.helpFormat()})
+}
+
+// resolveHelp returns the function writing the help described by the input
+// to the help command, which is the global help, the help of a command, or
+// the help of an argument. An error is returned if the input doesn't
+// describe any of the help.
+func (app *App) resolveHelp(input string) (func(Flags) []byte, error) {
 
 	// If there is no input left, original command must've been
 	// just the help command. Hence, run the global help command.
 	if input == "" {
-		return app.config.help(Flags{})
+		return app.currentConfig().help, nil
 	}
 
 	// Extract the command and reamining input after removing the input
 	command, remainingInput, err := app.extractCommand(input)
 	if err != nil {
-		return []byte(fmt.Sprintf("%v\n", err))
+		return nil, err
 	}
 
 	// If there is no remaining input, the original command must've
 	// been a single command followed by the help command.
 	if remainingInput == "" {
-		return command.help(Flags{})
+		return command.help, nil
 	}
 
-	// Get the argument and flags
-	argument, _, err := app.extractArgument(remainingInput, command)
-	if err != nil {
-		return []byte(fmt.Sprintf("%v\n", err))
+	// Get the argument, descending through any child arguments. If the
+	// child argument is missing, the help of the parent argument is used.
+	path, _, err := app.extractArguments(remainingInput, command)
+	if err != nil && (len(path) == 0 || len(path[len(path)-1].Arguments) == 0) {
+		return nil, err
 	}
 
 	// Return the argument version of the help.
-	return argument.help(Flags{})
+	return path[len(path)-1].help, nil
 }
 
 // extractCommand extracts the command string from the input.
@@ -193,70 +968,364 @@ func (app *App) extractCommand(input string) (command Command, remainingInput st
 
 	// Extract the command label
 	var commandLabel string
-	index := strings.Index(input, " ")
+	index := strings.IndexAny(input, app.currentConfig().delimiters())
 	if index == -1 {
 		commandLabel = input
 	} else {
 		commandLabel = input[:index]
-		remainingInput = strings.TrimLeft(input[index:], whitespaceCharacters)
+		remainingInput = strings.TrimLeft(input[index:], app.currentConfig().delimiters())
 	}
 
 	// Search for the command from the config
-	for _, cmd := range app.config.Commands {
-		if cmd.Label == commandLabel {
+	for _, cmd := range app.currentConfig().Commands {
+		if cmd.matches(commandLabel, app.currentConfig().CaseInsensitive) {
 			return cmd, remainingInput, nil
 		}
 	}
 
-	// Return an error if unable to find the command in the config
+	// Return an error if unable to find the command in the config,
+	// suggesting the closest command if the label is a likely typo
+	names := append(app.currentConfig().exitCmds(), app.currentConfig().helpCmds()...)
+	for _, cmd := range app.currentConfig().Commands {
+		names = append(names, cmd.names()...)
+	}
+	if closest, ok := app.currentConfig().closest(commandLabel, names); ok {
+		return command, remainingInput, fmt.Errorf("unable to find command \"%s\", did you mean \"%s\"?", commandLabel, closest)
+	}
 	return command, remainingInput, fmt.Errorf("unable to find command \"%s\"", commandLabel)
 }
 
-// extractArgument extracts the argument.
-// It also returns the options input, which is
-// the input with the argument label removed.
-func (app *App) extractArgument(remainingInput string, command Command) (argument Argument, optionsInput string, err error) {
+// argumentMatch is an argument whose label was found in the input,
+// between the start and end indexes.
+type argumentMatch struct {
+	argument   Argument
+	start, end int
+}
+
+// extractArguments extracts the argument and, if the argument has child
+// arguments, descends through the child arguments following it in the
+// input until reaching an argument without any. The argument extracted
+// at each level is returned in order, along with the options input,
+// which is the input with all the argument labels removed.
+func (app *App) extractArguments(remainingInput string, command Command) (path []Argument, optionsInput string, err error) {
+	label, arguments := command.Label, command.Arguments
+	for {
+		argument, before, after, err := app.matchArgument(remainingInput, label, arguments)
+		if err != nil {
+			return path, optionsInput + remainingInput, err
+		}
+		path = append(path, argument)
+
+		// The child arguments are only searched for after the argument label
+		if len(argument.Arguments) == 0 {
+			return path, optionsInput + before + app.currentConfig().separator() + after, nil
+		}
+		optionsInput += before + app.currentConfig().separator()
+		remainingInput = after
+		label, arguments = strings.TrimRight(label+" "+argument.Label, " "), argument.Arguments
+	}
+}
+
+// matchArgument finds the argument whose label is in the remaining input,
+// where label is the command label followed by the labels of any parent
+// arguments. It also returns the input before and after the argument label.
+func (app *App) matchArgument(remainingInput, label string, arguments []Argument) (argument Argument, before, after string, err error) {
 
-	// Attempt to find an argument that is in the remaining input
+	// Split the remaining input into tokens, so that the argument labels are
+	// only matched against whole tokens which are not options or their values.
+	// The input after the label of a raw argument is passed verbatim, so it
+	// may fail to be tokenized, e.g. (run sh echo it's), which is only an
+	// error if a raw argument isn't matched.
+	spans, tokenErr := tokenizeSpans(remainingInput, app.currentConfig().delimiters())
+	if tokenErr != nil && !hasRaw(arguments) {
+		return argument, before, after, tokenErr
+	}
+	options := make([]Option, 0)
+	for _, arg := range arguments {
+		options = append(options, arg.Options...)
+	}
+	spans = app.cutRaw(spans, arguments)
+
+	// Everything after the terminator (--) is passed through, so
+	// the argument labels are only matched before the terminator
+	for i, span := range spans {
+		if span.text == "--" {
+			spans = spans[:i]
+			break
+		}
+	}
+	spans = app.skipOptions(spans, options)
+
+	// Attempt to find every argument that is in the remaining input
 	var foundArg bool
-	for _, arg := range command.Arguments {
+	matches := make([]argumentMatch, 0)
+	for _, arg := range arguments {
 
 		// If argument label is empty, this represents a command with no arguments.
-		// However, this will be overriden if we continue iterating and find a match
-		// with an argument label that isn't empty.
+		// However, this will be overriden if we find a match with an argument label
+		// that isn't empty.
 		if arg.Label == "" {
-			optionsInput = remainingInput
+			after = remainingInput
 			argument = arg
 			foundArg = true
 			continue
 		}
 
-		// If the argument label is not in the remaining input, continue
-		index := strings.Index(remainingInput, arg.Label)
-		if index == -1 {
-			continue
+		// The default argument receives the text of the remaining input which
+		// isn't options if no argument label is matched, but its label is
+		// matched as usual
+		if arg.Default {
+			argument = arg
+			argument.unmatched, after = cutSpans(remainingInput, spans, app.currentConfig().separator())
+			foundArg = true
 		}
 
-		// Validation for the input after the argument
-		after := remainingInput[index+len(arg.Label):]
-		if after != "" && !strings.HasPrefix(after, " ") {
+		// If the argument label is not in the remaining input as whole tokens, continue
+		start, end, found := app.findLabel(spans, arg.Label)
+		if !found {
 			continue
 		}
 
-		// Set the options input as the remaining input with the argument label removed
-		// and break out of the loop
-		optionsInput = remainingInput[:index] + " " + after
-		argument = arg
+		matches = append(matches, argumentMatch{
+			argument: arg,
+			start:    start,
+			end:      end,
+		})
+	}
+
+	// Discard any match contained within a longer match,
+	// e.g. the label "tasks" within the label "daily tasks".
+	candidates := make([]argumentMatch, 0)
+	for i, match := range matches {
+		var nested bool
+		for j, other := range matches {
+			if i != j && other.start <= match.start && match.end <= other.end && other.end-other.start > match.end-match.start {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			candidates = append(candidates, match)
+		}
+	}
+
+	// If more than one argument matches, the input is ambiguous
+	if len(candidates) > 1 {
+		labels := make([]string, 0)
+		for _, candidate := range candidates {
+			labels = append(labels, fmt.Sprintf("\"%s\"", candidate.argument.Label))
+		}
+		return argument, before, after, fmt.Errorf("ambiguous use of the \"%s\" command, multiple arguments provided %s", label, strings.Join(labels, ", "))
+	}
+
+	// Split the remaining input either side of the argument label
+	if len(candidates) == 1 {
+		match := candidates[0]
+		before, after = remainingInput[:match.start], remainingInput[match.end:]
+		argument = match.argument
+		argument.matched = remainingInput[match.start:match.end]
 		foundArg = true
-		break
 	}
 
 	// If no matching argument has been found, return an error
 	if !foundArg {
-		return argument, optionsInput, fmt.Errorf("invalid use of the \"%s\" command, no valid argument provided", command.Label)
+		return argument, before, after, fmt.Errorf("invalid use of the \"%s\" command, no valid argument provided", label)
+	}
+	if tokenErr != nil && !argument.Raw {
+		return argument, before, after, tokenErr
 	}
 
-	return argument, optionsInput, nil
+	return argument, before, after, nil
+}
+
+// hasRaw returns whether any of the arguments is raw
+func hasRaw(arguments []Argument) bool {
+	for _, arg := range arguments {
+		if arg.Raw {
+			return true
+		}
+	}
+	return false
+}
+
+// cutRaw returns the tokens up to the end of the first label of a raw
+// argument found in the tokens, so that the input passed verbatim to the
+// raw argument is not matched against the argument labels.
+func (app *App) cutRaw(spans []tokenSpan, arguments []Argument) []tokenSpan {
+	cut := -1
+	for _, arg := range arguments {
+		if !arg.Raw || arg.Label == "" {
+			continue
+		}
+		if _, end, found := app.findLabel(spans, arg.Label); found && (cut == -1 || end < cut) {
+			cut = end
+		}
+	}
+	if cut == -1 {
+		return spans
+	}
+	for i, span := range spans {
+		if span.start >= cut {
+			return spans[:i]
+		}
+	}
+	return spans
+}
+
+// skipOptions returns the tokens which are not options, namely the tokens
+// which start with a dash (-), other than negative numbers, and the values
+// of any options which take the next token as their variable, e.g. "all"
+// in (-n all) or "NAME=VALUE" in (--env NAME=VALUE).
+func (app *App) skipOptions(spans []tokenSpan, options []Option) []tokenSpan {
+	tokens := make([]string, len(spans))
+	for i, span := range spans {
+		tokens[i] = span.text
+	}
+	remaining := make([]tokenSpan, 0, len(spans))
+	for i := 0; i < len(spans); i++ {
+		if !isOptionToken(spans[i].text, options) {
+			remaining = append(remaining, spans[i])
+			continue
+		}
+		name, hasValue := spans[i].text, false
+		if strings.HasPrefix(name, "--") {
+			name, _, hasValue = app.splitLong(name)
+		}
+		for _, option := range options {
+			if option.Short != name && option.Long != name {
+				continue
+			}
+			if takesNextValue(option, option.Short == name, hasValue, tokens, i) {
+				i++
+			}
+			break
+		}
+	}
+	return remaining
+}
+
+// takesNextValue returns whether the option given by the i-th token takes
+// the next token as its variable, namely whether the option has a variable
+// without a value given in the token, and is either given by its short name,
+// e.g. (-n all), or is a key value option, e.g. (--env NAME=VALUE), and the
+// next token can be a value.
+func takesNextValue(option Option, shortVersion, hasValue bool, tokens []string, i int) bool {
+	if option.Variable == nil || hasValue || i+1 >= len(tokens) || !isValue(tokens[i+1]) {
+		return false
+	}
+	return shortVersion || option.KeyValue
+}
+
+// isOptionToken returns whether the token is an option, namely whether it
+// starts with a dash (-) and isn't a negative number, unless the negative
+// number is the short name of one of the options, e.g. (-1).
+func isOptionToken(token string, options []Option) bool {
+	if !strings.HasPrefix(token, "-") {
+		return false
+	}
+	_, isShort := findShort(options, token)
+	return !isNegativeNumber(token) || isShort
+}
+
+// isValue returns whether the token can be the variable following the short
+// name of an option, namely whether it isn't an option or the terminator (--),
+// with the exception of negative numbers, e.g. "-5" or "-0.5".
+func isValue(token string) bool {
+	if !strings.HasPrefix(token, "-") {
+		return true
+	}
+	return isNegativeNumber(token)
+}
+
+// isNegativeNumber returns whether the token is a negative decimal number,
+// e.g. "-5" or "-0.5".
+func isNegativeNumber(token string) bool {
+	if len(token) < 2 || token[0] != '-' || !strings.ContainsRune("0123456789.", rune(token[1])) {
+		return false
+	}
+	_, err := strconv.ParseFloat(token, 64)
+	return err == nil
+}
+
+// followsSecret returns whether the token before the i-th token is a secret
+// option which has a variable, so that the i-th token may be its value.
+func (app *App) followsSecret(tokens []string, i int, options []Option) bool {
+	if i == 0 {
+		return false
+	}
+	name, _, _ := app.splitLong(tokens[i-1])
+	for _, option := range options {
+		if option.Secret && option.Variable != nil && (option.Short == name || option.Long == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// combinesSecret returns whether any of the characters of the combined short
+// flags is the short name of a secret option, e.g. (-thunter2) for (-t).
+func combinesSecret(token string, options []Option) bool {
+	for _, char := range strings.TrimPrefix(token, "-") {
+		if option, ok := findShort(options, "-"+string(char)); ok && option.Secret {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownOption returns the error for an option name which doesn't match
+// any of the options, suggesting the closest option name if it is a likely
+// typo of the name.
+func (app *App) unknownOption(name string, options []Option) error {
+	names := make([]string, 0, 2*len(options))
+	for _, option := range options {
+		for _, optionName := range []string{option.Long, option.Short} {
+			if optionName != "" {
+				names = append(names, optionName)
+			}
+		}
+	}
+	if closest, ok := app.currentConfig().closest(name, names); ok {
+		return fmt.Errorf("unknown option \"%s\", did you mean \"%s\"?", name, closest)
+	}
+	return fmt.Errorf("unknown option \"%s\"", name)
+}
+
+// cutSpans returns the text of the spans of the input, separated by the
+// separator, along with the rest of the input, which is the input with the
+// spans replaced by the separator.
+func cutSpans(input string, spans []tokenSpan, separator string) (text, rest string) {
+	texts := make([]string, 0, len(spans))
+	var previous int
+	for _, span := range spans {
+		texts = append(texts, input[span.start:span.end])
+		rest += input[previous:span.start] + separator
+		previous = span.end
+	}
+	return strings.Join(texts, separator), rest + input[previous:]
+}
+
+// findLabel returns where the first instance of the label is in the input, given
+// the tokens of the input. Each word of the label must match a whole token, in
+// consecutive tokens, so that a label is not matched within another word, e.g.
+// the label "all" within "--tag=allowed". The label is matched ignoring case if
+// the config is case insensitive.
+func (app *App) findLabel(spans []tokenSpan, label string) (start, end int, found bool) {
+	words := strings.Fields(label)
+	for i := 0; i+len(words) <= len(spans); i++ {
+		match := true
+		for j, word := range words {
+			token := spans[i+j].text
+			if token != word && !(app.currentConfig().CaseInsensitive && strings.EqualFold(token, word)) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return spans[i].start, spans[i+len(words)-1].end, true
+		}
+	}
+	return 0, 0, false
 }
 
 // extractFlags extracts the flags from the options input
@@ -265,43 +1334,113 @@ func (app *App) extractFlags(optionsInput string, argument Argument) (flags Flag
 	// Set the default flag metadata for the flags
 	metadata := make(map[string]flagMetadata, 0)
 	for _, option := range argument.Options {
-		metadata[option.Label] = flagMetadata{
-			isset:    false,
-			hasVar:   false,
-			variable: "",
-		}
+		metadata[option.Label] = newFlagMetadata(option)
 	}
 
 	// Loop though the option flags remaining in the input
 	// For each flag found, re-configure the flag metadata.
 	var expectingValue bool
-	optionsStrings := strings.Split(optionsInput, " ")
+	var passThrough, args []string
+	optionsStrings, err := tokenize(optionsInput, app.currentConfig().delimiters())
+	if err != nil {
+		return flags, err
+	}
 	for i, s := range optionsStrings {
 
-		// Ignore empty strings
-		if s == "" {
+		// Skip the value consumed by the preceding short option
+		if expectingValue {
+			expectingValue = false
+			continue
+		}
+
+		// Everything after the terminator (--) is passed through untouched
+		if s == "--" {
+			passThrough = append(make([]string, 0), optionsStrings[i+1:]...)
+			break
+		}
+
+		// A token following a secret option may be its value given with the
+		// wrong syntax, so it is masked in any error about the token
+		invalid := func(err error) (Flags, error) {
+			if app.followsSecret(optionsStrings, i, argument.Options) {
+				return flags, fmt.Errorf("invalid text \"%s\" detected", secretMask)
+			}
+			return flags, err
+		}
+
+		// A negative number e.g. (-5) is text rather than an option,
+		// unless it is the short name of one of the options
+		isOption := isOptionToken(s, argument.Options)
+
+		// Decompose combined short flags e.g. (-abc) into individual short flags.
+		// Only options which don't require a variable can be combined.
+		// The token is masked in any error if it may contain the value of a
+		// secret option, e.g. (-thunter2) for the secret option (-t).
+		if isOption && !strings.HasPrefix(s, "--") && len(s) > 2 {
+			token := s
+			if combinesSecret(s, argument.Options) {
+				token = secretMask
+			}
+			for _, char := range s[1:] {
+				short := "-" + string(char)
+				option, ok := findShort(argument.Options, short)
+				if !ok {
+					return invalid(fmt.Errorf("unknown option \"%s\" in \"%s\"", short, token))
+				}
+				if option.Variable != nil {
+					return invalid(fmt.Errorf("option \"%s\" in \"%s\" requires a variable and cannot be combined with other options", short, token))
+				}
+				clearFlags(metadata, option)
+				err := app.setFlag(metadata, flagMetadata{
+					option:   option,
+					isset:    true,
+					hasVar:   false,
+					variable: "",
+					source:   sourceInput,
+					raw:      s,
+				})
+				if err != nil {
+					return flags, err
+				}
+			}
 			continue
 		}
 
 		// Check if using a option short or long name
-		if strings.HasPrefix(s, "-") {
+		if isOption {
 			var variable string
-			var shortVersion bool
+			var shortVersion, found bool
+
+			// For long version, the option name is the text before any value separator
+			name, value, hasValue := s, "", false
+			if strings.HasPrefix(s, "--") {
+				name, value, hasValue = app.splitLong(s)
+			}
 
 			// Loop though all options
 			for _, option := range argument.Options {
-				if option.Short != s && option.Long != s {
+				if option.Short != name && option.Long != name {
 					continue
 				}
-				shortVersion = option.Short == s
+				shortVersion = option.Short == name
+				found = true
+
+				// Reset any options which are cleared by this option
+				clearFlags(metadata, option)
 
 				// If the option requires no variable, re-configure the flag metadata
 				// for this option as isset = true
 				if option.Variable == nil {
-					metadata[option.Label] = flagMetadata{
+					err := app.setFlag(metadata, flagMetadata{
+						option:   option,
 						isset:    true,
 						hasVar:   false,
 						variable: "",
+						source:   sourceInput,
+						raw:      s,
+					})
+					if err != nil {
+						return flags, err
 					}
 					break
 				}
@@ -310,45 +1449,314 @@ func (app *App) extractFlags(optionsInput string, argument Argument) (flags Flag
 				variable = option.Variable.Default
 
 				// For short version, syntax will be -<char> <variable> e.g. (-a read).
-				// We will use expectingValue = true to ignore the non-flag text in the
-				// next loop iteration.
+				// We will use expectingValue = true to skip the variable in the next
+				// loop iteration. The next token is not the variable if it is another
+				// option, unless it is a negative number e.g. (-a -5).
 				if shortVersion {
-					if i+1 < len(optionsStrings) {
+					source, raw := sourceDefault, s
+					if takesNextValue(option, true, false, optionsStrings, i) {
 						variable = optionsStrings[i+1]
+						source, raw = sourceInput, s+" "+variable
+						expectingValue = true
+					} else if env, ok := option.Variable.lookupEnv(); ok {
+						variable, source = env, sourceEnv
 					} else if option.Variable.Required {
 						return flags, fmt.Errorf("missing variable \"%s\" for option \"%s\"", option.Variable.Label, option.Label)
 					}
-					metadata[option.Label] = flagMetadata{
+					err := app.setFlag(metadata, flagMetadata{
+						option:   option,
 						isset:    true,
 						hasVar:   true,
 						variable: variable,
+						source:   source,
+						raw:      raw,
+					})
+					if err != nil {
+						return flags, err
 					}
-					expectingValue = true
 					break
 				}
 
 				// For long version, syntax will be --<chars>=<variable> e.g. (--append=true).
-				if index := strings.Index(s, "="); index != -1 {
-					variable = s[index:]
+				// A key value pair may also be the next token e.g. (--env NAME=VALUE).
+				source, raw := sourceDefault, s
+				if hasValue {
+					variable = value
+					source = sourceInput
+				} else if takesNextValue(option, false, false, optionsStrings, i) {
+					variable = optionsStrings[i+1]
+					source, raw = sourceInput, s+" "+variable
+					expectingValue = true
+				} else if env, ok := option.Variable.lookupEnv(); ok {
+					variable, source = env, sourceEnv
 				} else if option.Variable.Required {
 					return flags, fmt.Errorf("required option \"%s\" missing required variable \"%s\"", option.Label, option.Variable.Label)
 				}
-				metadata[option.Label] = flagMetadata{
+				err := app.setFlag(metadata, flagMetadata{
+					option:   option,
 					isset:    true,
 					hasVar:   true,
 					variable: variable,
+					source:   source,
+					raw:      raw,
+				})
+				if err != nil {
+					return flags, err
 				}
 				break
 			}
-		} else if !expectingValue {
-			return flags, fmt.Errorf("invalid text \"%s\" detected", s)
+
+			// The negation of a long option without a variable, e.g. (--no-color)
+			// for (--color), sets the option as explicitly false
+			if option, ok := findNegated(argument.Options, name); ok && !found && !hasValue {
+				clearFlags(metadata, option)
+				metadata[option.Label] = flagMetadata{
+					option:  option,
+					negated: true,
+					source:  sourceInput,
+					raw:     s,
+				}
+				found = true
+			}
+
+			// If strict, an option which doesn't match any of the options is an error
+			if !found && app.currentConfig().StrictOptions {
+				return invalid(app.unknownOption(name, argument.Options))
+			}
+		} else if argument.FreeForm {
+			args = append(args, s)
+		} else {
+			return invalid(fmt.Errorf("invalid text \"%s\" detected", s))
+		}
+	}
+
+	// Set any options omitted from the input from their environment variables
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if option.Variable == nil || meta.isset {
+			continue
+		}
+		if env, ok := option.Variable.lookupEnv(); ok {
+			meta.isset, meta.variable, meta.source = true, env, sourceEnv
+			metadata[option.Label] = meta
+		}
+	}
+
+	// Check at most one option of each exclusive group is set
+	for _, group := range argument.ExclusiveGroups {
+		set := make([]string, 0)
+		for _, label := range group {
+			if metadata[label].isset {
+				set = append(set, fmt.Sprintf("\"%s\"", label))
+			}
+		}
+		if len(set) > 1 {
+			return flags, fmt.Errorf("options %s cannot be used together", strings.Join(set, ", "))
+		}
+	}
+
+	// Check the values of any variables with choices are permitted
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if option.Variable == nil || !meta.isset {
+			continue
+		}
+		for _, value := range meta.values() {
+			if !option.Variable.isChoice(value) {
+				return flags, fmt.Errorf("invalid value \"%s\" for option \"%s\", must be one of %s", option.mask(value), option.Label, strings.Join(option.Variable.Choices, ", "))
+			}
+		}
+	}
+
+	// Check the values of any key value options are key value pairs
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if !option.KeyValue || !meta.isset || !meta.hasVar {
+			continue
+		}
+		for _, value := range meta.values() {
+			if _, _, ok := splitKeyValue(value); !ok {
+				return flags, fmt.Errorf("invalid key value pair \"%s\" for option \"%s\", must be key=value", option.mask(value), option.Label)
+			}
+		}
+	}
+
+	// Check the lengths of the values of any variables with a minimum or maximum length
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if option.Variable == nil || !meta.isset {
+			continue
+		}
+		for _, value := range meta.values() {
+			if err := option.Variable.checkLength(value); err != nil {
+				return flags, fmt.Errorf("option \"%s\", %s", option.Label, option.redact(err, value))
+			}
+		}
+	}
+
+	// Check the values of any variables with a pattern match the pattern
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if option.Variable == nil || option.Variable.Pattern == "" || !meta.isset {
+			continue
+		}
+		for _, value := range meta.values() {
+			if err := option.Variable.checkPattern(value); err != nil {
+				return flags, fmt.Errorf("option \"%s\", %s", option.Label, option.redact(err, value))
+			}
+		}
+	}
+
+	// Convert the values of any variables with a unit
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if option.Variable == nil || option.Variable.Unit == "" || !meta.isset {
+			continue
+		}
+		variable, err := convertUnit(option.Variable.Unit, meta.variable)
+		if err != nil {
+			return flags, fmt.Errorf("option \"%s\", %s", option.Label, option.redact(err, meta.variable))
+		}
+		meta.variable = variable
+		for i, value := range meta.variables {
+			if meta.variables[i], err = convertUnit(option.Variable.Unit, value); err != nil {
+				return flags, fmt.Errorf("option \"%s\", %s", option.Label, option.redact(err, value))
+			}
+		}
+		metadata[option.Label] = meta
+	}
+
+	// Check the paths exist for any path variables which must exist
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if option.Variable == nil || option.Variable.Type != typePath || !option.Variable.MustExist || !meta.isset {
+			continue
+		}
+		if err := checkPath(meta.variable); err != nil {
+			return flags, fmt.Errorf("option \"%s\", %s", option.Label, option.redact(err, meta.variable))
+		}
+	}
+
+	// Read the content of the files referenced by any object variables
+	for _, option := range argument.Options {
+		meta := metadata[option.Label]
+		if option.Variable == nil || !option.Variable.ObjectRef || !meta.isset || meta.variable == "" {
+			continue
+		}
+		object, err := os.ReadFile(meta.variable)
+		if err != nil {
+			return flags, fmt.Errorf("unable to read object for option \"%s\", %s", option.Label, option.redact(err, meta.variable))
+		}
+		meta.object = object
+		metadata[option.Label] = meta
+	}
+
+	return Flags{mapping: metadata, passThrough: passThrough, args: args, longSeparator: app.currentConfig().longValueSeparators()[0]}, nil
+}
+
+// setFlag sets the metadata of an option found in the input. The values of a
+// repeatable option given more than once are collected, whereas an option which
+// is not repeatable takes the last value, or returns an error if configured.
+func (app *App) setFlag(metadata map[string]flagMetadata, meta flagMetadata) error {
+	previous := metadata[meta.option.Label]
+	if previous.isset && !meta.option.Repeatable && app.currentConfig().ErrorOnDuplicate {
+		return fmt.Errorf("option \"%s\" provided more than once", meta.option.Label)
+	}
+	meta.count = previous.count + 1
+	if meta.option.Repeatable && meta.hasVar {
+		if previous.isset {
+			meta.variables = append(previous.variables, meta.variable)
+			meta.raw = previous.raw + " " + meta.raw
+		} else {
+			meta.variables = []string{meta.variable}
+		}
+	}
+	metadata[meta.option.Label] = meta
+	return nil
+}
+
+// splitLong splits a long option token into the option name and the value
+// following the first of the configured long value separators, if any.
+func (app *App) splitLong(s string) (name, value string, hasValue bool) {
+	index, length := -1, 0
+	for _, separator := range app.currentConfig().longValueSeparators() {
+		if i := strings.Index(s, separator); i != -1 && (index == -1 || i < index) {
+			index, length = i, len(separator)
+		}
+	}
+	if index == -1 {
+		return s, "", false
+	}
+	return s[:index], s[index+length:], true
+}
+
+// checkRequired returns an error listing any required options which have not been set,
+// or any options required by a set option which have not been set
+func checkRequired(argument Argument, flags Flags) error {
+	missing := make([]string, 0)
+	for _, option := range argument.Options {
+		if option.Required && !flags.IsSet(option.Label) {
+			missing = append(missing, fmt.Sprintf("\"%s\"", option.Label))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required options %s", strings.Join(missing, ", "))
+	}
+
+	// Options required by a set option must also be set
+	for _, option := range argument.Options {
+		if !flags.IsSet(option.Label) {
+			continue
+		}
+		for _, label := range option.Requires {
+			if !flags.IsSet(label) {
+				missing = append(missing, fmt.Sprintf("\"%s\"", label))
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("option \"%s\" missing required options %s", option.Label, strings.Join(missing, ", "))
 		}
 	}
+	return nil
+}
+
+// findShort returns the option with the given short name
+func findShort(options []Option, short string) (option Option, ok bool) {
+	for _, option := range options {
+		if option.Short == short {
+			return option, true
+		}
+	}
+	return option, false
+}
+
+// negationPrefix is the prefix of the negation of a long option, e.g. (--no-color)
+const negationPrefix = "--no-"
 
-	return Flags{mapping: metadata}, nil
+// findNegated returns the option without a variable which the given long name
+// negates, e.g. the option for (--color) for (--no-color).
+func findNegated(options []Option, long string) (option Option, ok bool) {
+	if !strings.HasPrefix(long, negationPrefix) {
+		return option, false
+	}
+	for _, option := range options {
+		if option.Variable == nil && option.Long == "--"+strings.TrimPrefix(long, negationPrefix) {
+			return option, true
+		}
+	}
+	return option, false
+}
+
+// clearFlags resets the metadata of any options which are cleared by the option
+func clearFlags(metadata map[string]flagMetadata, option Option) {
+	for _, label := range option.Clears {
+		if meta, ok := metadata[label]; ok {
+			metadata[label] = newFlagMetadata(meta.option)
+		}
+	}
 }
 
 // prepareExit prepare the CLI to exit after the next output has been sent
 func (app *App) prepareExit() {
-	app.active = false
+	app.active.Store(false)
 }
@@ -0,0 +1,1023 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// reloadConfig returns a config with a single command, echo, which
+// returns the name of the config.
+func reloadConfig(t *testing.T, name string) *Config {
+	t.Helper()
+	config := loadTestConfig(t, `
+commands:
+  - label: `+name+`
+    arguments:
+      - label: ""
+        execFunc: Echo
+`)
+	if err := config.WithFuncs(map[string]func(Flags) []byte{"Init": output(""), "Exit": output(""), "Echo": output(name + "\n")}); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestReload(t *testing.T) {
+	app := NewWithIO(reloadConfig(t, "old"), strings.NewReader(""), io.Discard)
+	if got := string(app.Execute("old")); got != "old\n" {
+		t.Fatalf("Execute(old) = %q before reload", got)
+	}
+	if err := app.Reload(reloadConfig(t, "new")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"new", "new\n"},
+		{"old", "unable to find command \"old\"\n"},
+	}
+	for _, test := range tests {
+		if got := string(app.Execute(test.input)); got != test.want {
+			t.Errorf("Execute(%q) = %q after reload, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	app := NewWithIO(reloadConfig(t, "old"), strings.NewReader(""), io.Discard)
+	if err := app.Reload(&Config{ExitCmd: Names{"exit"}}); err == nil {
+		t.Fatal("expected an error reloading an invalid config")
+	}
+	if got := string(app.Execute("old")); got != "old\n" {
+		t.Errorf("Execute(old) = %q, want the current config to be kept", got)
+	}
+}
+
+func TestReloadWhileReading(t *testing.T) {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	app := NewWithIO(reloadConfig(t, "old"), inReader, outWriter)
+	app.currentConfig().Prompt = "> "
+	done := make(chan struct{})
+	go func() {
+		app.Run()
+		outWriter.Close()
+		close(done)
+	}()
+	out := bufio.NewReader(outReader)
+
+	// The prompt is written before the input is read, so the input typed
+	// after the config is reloaded must be handled by the new config.
+	readUntil := func(want string) {
+		t.Helper()
+		var got string
+		for !strings.HasSuffix(got, want) {
+			b, err := out.ReadByte()
+			if err != nil {
+				t.Fatalf("expected %q in the output, got %q: %v", want, got, err)
+			}
+			got += string(b)
+		}
+	}
+	readUntil("> ")
+	io.WriteString(inWriter, "old\n")
+	readUntil("old\n> ")
+	if err := app.Reload(reloadConfig(t, "new")); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(inWriter, "new\n")
+	readUntil("new\n")
+
+	// Stopping the CLI reads the config on another goroutine
+	go io.Copy(io.Discard, out)
+	app.Stop()
+	<-done
+	inWriter.Close()
+}
+
+func TestReloadWhileChecking(t *testing.T) {
+	app := NewWithIO(reloadConfig(t, "old"), strings.NewReader(""), io.Discard)
+	configs := []*Config{reloadConfig(t, "old"), reloadConfig(t, "new")}
+	done := make(chan struct{})
+
+	// Execute swaps in the reloaded config, as the goroutine handling the
+	// inputs would, while the config is read by Check and Parse
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := app.Reload(configs[i%2]); err != nil {
+				t.Error(err)
+				return
+			}
+			app.Execute("old")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		for _, input := range []string{"old", "new"} {
+			if err := app.Check(input); err != nil && err.Error() != fmt.Sprintf("unable to find command \"%s\"", input) {
+				t.Errorf("Check(%q) = %v, want it to be checked with either config", input, err)
+			}
+			app.Parse(input)
+		}
+	}
+	<-done
+}
+
+const fallbackConfig = `
+fallbackFunc: Fallback
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+`
+
+func TestFallback(t *testing.T) {
+	fallback := func(flags Flags) []byte { return []byte("fallback " + flags.Positional() + "\n") }
+	tests := []struct {
+		name     string
+		fallback bool
+		input    string
+		want     string
+		wantErr  bool
+	}{
+		{"command", true, "get all", "all {}\n", false},
+		{"unknown command", true, "2 + 2", "fallback 2 + 2\n", false},
+		{"unknown argument", true, "get none", "invalid use of the \"get\" command, no valid argument provided\n", true},
+		{"without fallback", false, "2 + 2", "unable to find command \"2\"\n", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fallbackConfig
+			funcs := map[string]func(Flags) []byte{"GetAll": echo("all"), "Fallback": fallback}
+			if !test.fallback {
+				config = strings.Replace(config, "fallbackFunc: Fallback\n", "", 1)
+				delete(funcs, "Fallback")
+			}
+			app, _ := newTestApp(t, config, funcs, "")
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+			if err := app.Check(test.input); (err != nil) != test.wantErr {
+				t.Errorf("Check(%q) = %v, want error %t", test.input, err, test.wantErr)
+			}
+			command, _, flags, err := app.Parse(test.input)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Parse(%q) = %v, want error %t", test.input, err, test.wantErr)
+			}
+			if test.fallback && command.Label == "" && err == nil && flags.Positional() != test.input {
+				t.Errorf("Parse(%q) positional = %q, want the input", test.input, flags.Positional())
+			}
+		})
+	}
+}
+
+const dryRunConfig = `
+dryRunFlag: --dry-run
+strictOptions: true
+fallbackFunc: Fallback
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: quiet
+            short: -q
+`
+
+func TestDryRun(t *testing.T) {
+	ran := 0
+	run := func(flags Flags) []byte {
+		ran++
+		return []byte(flags.String())
+	}
+	app, _ := newTestApp(t, dryRunConfig, map[string]func(Flags) []byte{"GetAll": run, "Fallback": run}, "")
+	tests := []struct {
+		input  string
+		dryRun string
+		want   string
+	}{
+		{"get all", "get all --dry-run", "dry run: command \"get\", argument \"all\", flags %s\n"},
+		{"get all -q", "get all --dry-run -q", "dry run: command \"get\", argument \"all\", flags %s\n"},
+		{"get all -q", "--dry-run get all -q", "dry run: command \"get\", argument \"all\", flags %s\n"},
+		{"2 + 2", "2 + 2 --dry-run", "dry run: fallback, input \"2 + 2\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.dryRun, func(t *testing.T) {
+			ran = 0
+			got := string(app.Execute(test.dryRun))
+			if ran != 0 {
+				t.Errorf("Execute(%q) ran the executable", test.dryRun)
+			}
+			if err := app.Check(test.dryRun); err != nil {
+				t.Errorf("Check(%q) = %v, want no error", test.dryRun, err)
+			}
+			if _, _, _, err := app.Parse(test.dryRun); err != nil {
+				t.Errorf("Parse(%q) = %v, want no error", test.dryRun, err)
+			}
+
+			// The dry run describes the flags the executable is given
+			want := test.want
+			if strings.Contains(want, "%s") {
+				want = fmt.Sprintf(want, app.Execute(test.input))
+			}
+			if got != want {
+				t.Errorf("Execute(%q) = %q, want %q", test.dryRun, got, want)
+			}
+		})
+	}
+}
+
+const argsConfig = `
+commands:
+  - label: echo
+    arguments:
+      - label: ""
+        execFunc: Echo
+        freeForm: true
+`
+
+func TestRunArgs(t *testing.T) {
+	echoArgs := func(flags Flags) []byte { return []byte(fmt.Sprintf("%q\n", flags.Args())) }
+	tests := []struct {
+		name        string
+		args        []string
+		interactive bool
+		in          string
+		want        string
+	}{
+		{"single shot", []string{"echo", "a", "b"}, false, "echo ignored\n", "hello\n[\"a\" \"b\"]\nbye\n"},
+		{"exit command", []string{"exit"}, false, "", "hello\nbye\n"},
+		{"interactive", []string{"echo", "a"}, true, "echo b\n", "hello\n[\"a\"]\n[\"b\"]\nbye\n"},
+		{"no args", nil, false, "echo b\n", "hello\n[\"b\"]\nbye\n"},
+		{"delimiters and quotes", []string{"echo", "a b", `"c"`, `d\`}, false, "", "hello\n[\"a b\" \"\\\"c\\\"\" \"d\\\\\"]\nbye\n"},
+		{"empty", []string{"echo", "", "a"}, false, "", "hello\n[\"\" \"a\"]\nbye\n"},
+		{"line break", []string{"echo", "a\nexit"}, false, "", "hello\n[\"a\\nexit\"]\nbye\n"},
+		{"comment", []string{"#echo"}, false, "", "hello\nunable to find command \"#echo\", did you mean \"echo\"?\nbye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			funcs := map[string]func(Flags) []byte{"Init": output("hello\n"), "Exit": output("bye\n"), "Echo": echoArgs}
+			app, out := newTestApp(t, argsConfig, funcs, test.in)
+			app.currentConfig().InteractiveAfterArgs = test.interactive
+			app.RunArgs(test.args)
+			if got := out.String(); got != test.want {
+				t.Errorf("RunArgs(%q) wrote %q, want %q", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+// pipeWriter is a writer to a pipe which is closed by the reader once the
+// given number of writes have been made.
+type pipeWriter struct {
+	writes int
+	err    error
+}
+
+func (w *pipeWriter) Write(b []byte) (int, error) {
+	if w.writes == 0 {
+		return 0, w.err
+	}
+	w.writes--
+	return len(b), nil
+}
+
+func TestClosedPipe(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes int
+		err    error
+	}{
+		{"broken pipe at start", 0, syscall.EPIPE},
+		{"broken pipe after output", 2, &os.PathError{Op: "write", Path: "/dev/stdout", Err: syscall.EPIPE}},
+		{"closed pipe", 1, io.ErrClosedPipe},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var exited, executed int
+			funcs := map[string]func(Flags) []byte{
+				"Init":   output("hello\n"),
+				"Exit":   func(Flags) []byte { exited++; return []byte("bye\n") },
+				"GetAll": func(Flags) []byte { executed++; return []byte("all\n") },
+			}
+			config := loadTestConfig(t, getConfig)
+			if err := config.WithFuncs(funcs); err != nil {
+				t.Fatal(err)
+			}
+			app := NewWithIO(config, strings.NewReader(strings.Repeat("get all\n", 5)), &pipeWriter{writes: test.writes, err: test.err})
+			app.Run()
+			if exited != 1 {
+				t.Errorf("exit function ran %d times, want once", exited)
+			}
+			if executed > test.writes {
+				t.Errorf("executed %d commands after the pipe was closed", executed)
+			}
+			if app.active.Load() {
+				t.Error("expected the CLI to stop once the pipe was closed")
+			}
+		})
+	}
+}
+
+const checkConfig = `
+longValueSeparators: ["="]
+strictOptions: true
+resetCmd: reset
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: number
+            short: -n
+            long: --number
+            required: true
+            variable:
+              label: number
+              choices: ["1", "2"]
+`
+
+func TestCheck(t *testing.T) {
+	executed := false
+	app, _ := newTestApp(t, checkConfig, map[string]func(Flags) []byte{"GetAll": func(Flags) []byte {
+		executed = true
+		return nil
+	}}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"get all -n 1", ""},
+		{"get all --number=2", ""},
+		{"", ""},
+		{"exit", ""},
+		{"reset", ""},
+		{"help", ""},
+		{"get help", ""},
+		{"get all help", ""},
+		{"got all -n 1", `unable to find command "got", did you mean "get"?`},
+		{"get", `invalid use of the "get" command, no valid argument provided`},
+		{"get none", `invalid use of the "get" command, no valid argument provided`},
+		{"get all", `missing required options "number"`},
+		{"get all -n 3", `invalid value "3" for option "number", must be one of 1, 2`},
+		{"get all -n 1 -x", `unknown option "-x", did you mean "-n"?`},
+		{`get all -n "1`, `unterminated quote (") detected`},
+		{"nothing help", `unable to find command "nothing"`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			err := app.Check(test.input)
+			if got := fmt.Sprint(err); (err == nil && test.want != "") || (err != nil && got != test.want) {
+				t.Errorf("Check(%q) = %v, want %q", test.input, err, test.want)
+			}
+		})
+	}
+	if executed {
+		t.Error("Check ran the executable")
+	}
+}
+
+func TestRunEOF(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"command then EOF", "get all\n", "hello\n> all\n> bye\n"},
+		{"command without a line ending", "get all", "hello\n> all\nbye\n"},
+		{"no input", "", "hello\n> bye\n"},
+		{"exit before EOF", "exit\nget all\n", "hello\n> bye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			funcs := map[string]func(Flags) []byte{"Init": output("hello\n"), "Exit": output("bye\n"), "GetAll": output("all\n")}
+			app, out := newTestApp(t, "prompt: \"> \"\n"+getConfig, funcs, test.in)
+			app.Run()
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	format := func(flags Flags) []byte { return []byte(flags.GetVarOrDefault("format") + "\n") }
+	funcs := map[string]func(Flags) []byte{"Init": output("welcome\n"), "Users": format, "Groups": format}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"sticky value cleared", "show users --format=json", "table\n"},
+		{"default kept", "show users", "table\n"},
+		{"option not sticky", "show groups --format=yaml", "table\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, out := newTestApp(t, stickyConfig, funcs, "")
+			app.Execute(test.input)
+			app.State().Set("user", "alice")
+			if err := app.Reset(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != "welcome\n" {
+				t.Errorf("Reset() wrote %q, want the init output re-emitted", out.String())
+			}
+			if _, ok := app.State().Get("user"); ok {
+				t.Error("Reset() kept the values stored in the state")
+			}
+			if got := string(app.Execute("show users")); got != test.want {
+				t.Errorf("Execute(\"show users\") after Reset() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewWithIO(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"commands", "get all\nget all -q\nexit\n", "hello\n> all {quiet: set=false}\n> all {quiet: set=true}\n> bye\n"},
+		{"error", "got all\nexit\n", "hello\n> unable to find command \"got\", did you mean \"get\"?\n> bye\n"},
+		{"help", "get help\nexit\n", "hello\n> \nUsage: get\n\nget all\n\tall \n\nget all -q\n\t-q  \n\n> bye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := loadTestConfig(t, "prompt: \"> \"\n"+getConfig)
+			funcs := map[string]func(Flags) []byte{"Init": output("hello\n"), "Exit": output("bye\n"), "GetAll": echo("all")}
+			if err := c.WithFuncs(funcs); err != nil {
+				t.Fatal(err)
+			}
+			in, out := bytes.NewBufferString(test.in), new(bytes.Buffer)
+			app := NewWithIO(c, in, out)
+			app.Run()
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+			if in.Len() != 0 {
+				t.Errorf("Run() left %q unread", in.String())
+			}
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		active bool
+	}{
+		{"get all", "all {quiet: set=false}\n", true},
+		{"get all -q", "all {quiet: set=true}\n", true},
+		{"  get   all  -q ", "all {quiet: set=true}\n", true},
+		{"", "", true},
+		{"get help", "\nUsage: get\n\nget all\n\tall \n\nget all -q\n\t-q  \n\n", true},
+		{"get all -x", "all {quiet: set=false}\n", true},
+		{"got all", "unable to find command \"got\", did you mean \"get\"?\n", true},
+		{"exit", "bye\n", false},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			funcs := map[string]func(Flags) []byte{"Exit": output("bye\n"), "GetAll": echo("all")}
+			app, out := newTestApp(t, getConfig, funcs, "get all\n")
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+			if active := app.active.Load(); active != test.active {
+				t.Errorf("Execute(%q) left the App active %t, want %t", test.input, active, test.active)
+			}
+			if out.Len() != 0 {
+				t.Errorf("Execute(%q) wrote %q, want the output only returned", test.input, out.String())
+			}
+		})
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		script string
+		want   string
+	}{
+		{"commands", "", "get all\nget all -q\n", "hello\nall {quiet: set=false}\nall {quiet: set=true}\nbye\n"},
+		{"comments", "", "# fetch everything\nget all\n  # quietly\nget all -q\n#get all\n", "hello\nall {quiet: set=false}\nall {quiet: set=true}\nbye\n"},
+		{"exit", "", "get all\nexit\nget all -q\n", "hello\nall {quiet: set=false}\nbye\n"},
+		{"blank lines", "", "\nget all\n\n", "hello\nall {quiet: set=false}\nbye\n"},
+		{"without a line ending", "", "get all", "hello\nall {quiet: set=false}\nbye\n"},
+		{"error", "", "got all\nget all\n", "hello\nunable to find command \"got\", did you mean \"get\"?\nall {quiet: set=false}\nbye\n"},
+		{"empty", "", "", "hello\nbye\n"},
+		{"comments after delimiters", "delimiters: \",\"\n", ",,# fetch everything\nget,all\n", "hello\nall {quiet: set=false}\nbye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			funcs := map[string]func(Flags) []byte{"Init": output("hello\n"), "Exit": output("bye\n"), "GetAll": echo("all")}
+			app, out := newTestApp(t, "prompt: \"> \"\n"+test.config+getConfig, funcs, "")
+			if err := app.RunScript(strings.NewReader(test.script)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.want {
+				t.Errorf("RunScript() wrote %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestRunScriptErrors(t *testing.T) {
+	app, _ := newTestApp(t, getConfig, map[string]func(Flags) []byte{"GetAll": echo("all")}, "")
+	if err := app.RunScript(errReader{io.ErrUnexpectedEOF}); err != io.ErrUnexpectedEOF {
+		t.Errorf("RunScript() = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	app, _ = newTestApp(t, getConfig, map[string]func(Flags) []byte{"Init": output("hello\n"), "GetAll": echo("all")}, "")
+	app.writer = bufio.NewWriter(&pipeWriter{err: io.ErrClosedPipe})
+	if err := app.RunScript(strings.NewReader("get all\n")); err != io.ErrClosedPipe {
+		t.Errorf("RunScript() = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+// contextProgram is a program with methods observing the context of the command
+type contextProgram struct {
+	started chan struct{}
+	ctx     chan context.Context
+}
+
+func (p contextProgram) Init(Flags) []byte { return nil }
+func (p contextProgram) Exit(Flags) []byte { return []byte("bye\n") }
+
+func (p contextProgram) Wait(ctx context.Context, flags Flags) []byte {
+	close(p.started)
+	select {
+	case <-ctx.Done():
+		return []byte("cancelled " + ctx.Err().Error() + "\n")
+	case <-time.After(5 * time.Second):
+		return []byte("not cancelled\n")
+	}
+}
+
+func (p contextProgram) Keep(ctx context.Context, flags Flags) []byte {
+	p.ctx <- ctx
+	return []byte(fmt.Sprintf("done %v\n", ctx.Err()))
+}
+
+func (p contextProgram) Plain(flags Flags) []byte {
+	return []byte(fmt.Sprintf("plain %v\n", flags.Context().Err()))
+}
+
+const contextConfig = `
+commands:
+  - label: wait
+    arguments:
+      - label: ""
+        execFunc: Wait
+  - label: keep
+    arguments:
+      - label: ""
+        execFunc: Keep
+  - label: plain
+    arguments:
+      - label: ""
+        execFunc: Plain
+`
+
+func TestContextCancelled(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		interrupt bool
+		want      string
+	}{
+		{"cancelled mid-command", "wait\n", true, "cancelled context canceled\nbye\n"},
+		{"not cancelled", "keep\n", false, "done <nil>\nbye\n"},
+		{"plain executable", "plain\n", false, "plain <nil>\nbye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := contextProgram{started: make(chan struct{}), ctx: make(chan context.Context, 1)}
+			out := new(bytes.Buffer)
+			app, err := NewWithIO(loadTestConfig(t, contextConfig), strings.NewReader(test.input), out).Using(program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan struct{})
+			go func() {
+				app.Run()
+				close(done)
+			}()
+			if test.interrupt {
+				<-program.started
+				app.sigint <- os.Interrupt
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run() didn't return")
+			}
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+			select {
+			case ctx := <-program.ctx:
+				if ctx.Err() == nil {
+					t.Error("the context wasn't cancelled once the command finished")
+				}
+			default:
+			}
+		})
+	}
+}
+
+// errorProgram is a program with a method returning an error
+type errorProgram struct{}
+
+func (errorProgram) Init(Flags) []byte { return nil }
+func (errorProgram) Exit(Flags) []byte { return nil }
+
+func (errorProgram) Remove(flags Flags) ([]byte, error) {
+	name, _ := flags.GetVar("name")
+	if name == "missing" {
+		return []byte("partial output\n"), fmt.Errorf("unable to remove \"%s\"", name)
+	}
+	return []byte("removed " + name + "\n"), nil
+}
+
+func (errorProgram) List(Flags) []byte { return []byte("listed\n") }
+
+func TestLastError(t *testing.T) {
+	config := loadTestConfig(t, `
+commands:
+  - label: remove
+    arguments:
+      - label: ""
+        execFunc: Remove
+        options:
+          - label: name
+            short: -n
+            variable:
+              label: name
+  - label: list
+    arguments:
+      - label: ""
+        execFunc: List
+`)
+	errOut := new(bytes.Buffer)
+	app, err := NewWithIO(config, strings.NewReader(""), io.Discard).WithErrWriter(errOut).Using(errorProgram{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input   string
+		want    string
+		lastErr string
+		errOut  string
+	}{
+		{"remove -n a", "removed a\n", "", ""},
+		{"remove -n missing", "partial output\n", `unable to remove "missing"`, "unable to remove \"missing\"\n"},
+		{"remove -n b", "removed b\n", "", ""},
+		{"remove -n missing", "partial output\n", `unable to remove "missing"`, "unable to remove \"missing\"\n"},
+		{"list", "listed\n", "", ""},
+	}
+	for _, test := range tests {
+		errOut.Reset()
+		if got := string(app.Execute(test.input)); got != test.want {
+			t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+		}
+		if got := fmt.Sprint(app.LastError()); (test.lastErr == "" && app.LastError() != nil) || (test.lastErr != "" && got != test.lastErr) {
+			t.Errorf("LastError() after %q = %v, want %q", test.input, app.LastError(), test.lastErr)
+		}
+		if errOut.String() != test.errOut {
+			t.Errorf("Execute(%q) wrote %q to the error stream, want %q", test.input, errOut.String(), test.errOut)
+		}
+	}
+}
+
+func TestLastErrorConcurrent(t *testing.T) {
+	config := loadTestConfig(t, `
+commands:
+  - label: remove
+    arguments:
+      - label: ""
+        execFunc: Remove
+        options:
+          - label: name
+            short: -n
+            variable:
+              label: name
+`)
+	app, err := NewWithIO(config, strings.NewReader(""), io.Discard).WithErrWriter(io.Discard).Using(errorProgram{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			app.Execute([]string{"remove -n a", "remove -n missing"}[i%2])
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := app.LastError(); err != nil && err.Error() != `unable to remove "missing"` {
+			t.Fatalf("LastError() = %v, want the error of the last command", err)
+		}
+	}
+	<-done
+}
+
+// promptProgram is a program with a prompt counting the reads
+type promptProgram struct{ count *int }
+
+func (p promptProgram) Init(Flags) []byte { return nil }
+func (p promptProgram) Exit(Flags) []byte { return []byte("bye\n") }
+func (p promptProgram) Echo(Flags) []byte { return []byte("ok\n") }
+func (p promptProgram) BadPrompt() []byte { return nil }
+
+func (p promptProgram) Prompt() string {
+	*p.count++
+	return fmt.Sprintf("[%d]> ", *p.count)
+}
+
+func TestPromptFunc(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt string
+		in     string
+		want   string
+		err    string
+	}{
+		{"counter", "promptFunc: Prompt\n", "echo\necho\nexit\n", "[1]> ok\n[2]> ok\n[3]> bye\n", ""},
+		{"over the static prompt", "prompt: \"$ \"\npromptFunc: Prompt\n", "echo\n", "[1]> ok\n[2]> bye\n", ""},
+		{"static prompt", "prompt: \"$ \"\n", "echo\n", "$ ok\n$ bye\n", ""},
+		{"missing", "promptFunc: Missing\n", "", "", `unable to find method "Missing" for type "cli.promptProgram"`},
+		{"invalid type", "promptFunc: BadPrompt\n", "", "", `method "BadPrompt" for type "cli.promptProgram" has invalid type "func() []uint8", must be func() string`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := loadTestConfig(t, test.prompt+"commands:\n  - label: echo\n    arguments:\n      - label: \"\"\n        execFunc: Echo\n")
+			out := new(bytes.Buffer)
+			app, err := NewWithIO(config, strings.NewReader(test.in), out).Using(promptProgram{count: new(int)})
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Using() = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Run()
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestVersionCmd(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		input   string
+		want    string
+	}{
+		{"version", "version: 1.2.3\nversionCmd: version\n", "version", "1.2.3\n"},
+		{"custom label", "version: 1.2.3\nversionCmd: --version\n", "--version", "1.2.3\n"},
+		{"with delimiters", "version: 1.2.3\nversionCmd: version\n", "  version ", "1.2.3\n"},
+		{"with other input", "version: 1.2.3\nversionCmd: version\n", "version all", "unable to find command \"version\"\n"},
+		{"disabled", "version: 1.2.3\n", "version", "unable to find command \"version\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, _ := newTestApp(t, test.version+getConfig, map[string]func(Flags) []byte{"GetAll": echo("all")}, "")
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestContinuesLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`echo a \`, true},
+		{`echo a\`, true},
+		{`echo a \\`, false},
+		{`echo a \\\`, true},
+		{`echo a`, false},
+		{`\`, true},
+		{``, false},
+	}
+	for _, test := range tests {
+		if got := continuesLine(test.line); got != test.want {
+			t.Errorf("continuesLine(%q) = %t, want %t", test.line, got, test.want)
+		}
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	echoArgs := func(flags Flags) []byte { return []byte(fmt.Sprintf("%q\n", flags.Args())) }
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"two lines", "echo a \\\nb\n", "$ > [\"a\" \"b\"]\n$ "},
+		{"three lines", "echo a \\\nb \\\nc\n", "$ > > [\"a\" \"b\" \"c\"]\n$ "},
+		{"without a space", "echo a\\\nb\n", "$ > [\"a\" \"b\"]\n$ "},
+		{"trailing whitespace", "echo a \\  \nb\n", "$ > [\"a\" \"b\"]\n$ "},
+		{"command split", "ec\\\nho a\n", "$ > unable to find command \"ec\"\n$ "},
+		{"escaped backslash", "echo a \\\\\necho b\n", "$ [\"a\" \"\\\\\\\\\"]\n$ [\"b\"]\n$ "},
+		{"continued at the end of the input", "echo a \\\n", "$ > [\"a\"]\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, out := newTestApp(t, "prompt: \"$ \"\n"+argsConfig, map[string]func(Flags) []byte{"Echo": echoArgs}, test.in)
+			app.Run()
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		stops int
+		want  string
+	}{
+		{"waiting for input", "", 1, "hello\n$ bye\n"},
+		{"after a command", "get all\n", 1, "hello\n$ all {quiet: set=false}\n$ bye\n"},
+		{"stopped twice", "", 2, "hello\n$ bye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var exits atomic.Int32
+			config := loadTestConfig(t, "prompt: \"$ \"\n"+getConfig)
+			funcs := map[string]func(Flags) []byte{
+				"Init":   output("hello\n"),
+				"GetAll": echo("all"),
+				"Exit": func(Flags) []byte {
+					exits.Add(1)
+					return []byte("bye\n")
+				},
+			}
+			if err := config.WithFuncs(funcs); err != nil {
+				t.Fatal(err)
+			}
+			inReader, inWriter := io.Pipe()
+			defer inWriter.Close()
+			out := new(bytes.Buffer)
+			outReader, outWriter := io.Pipe()
+			app := NewWithIO(config, inReader, outWriter)
+			done := make(chan struct{})
+			go func() {
+				app.Run()
+				outWriter.Close()
+				close(done)
+			}()
+
+			// Wait for the prompt following the input, so that the CLI is
+			// waiting for input when it is stopped
+			reader := bufio.NewReader(io.TeeReader(outReader, out))
+			if test.input != "" {
+				go io.WriteString(inWriter, test.input)
+			}
+			for strings.Count(out.String(), "$ ") <= strings.Count(test.input, "\n") {
+				if _, err := reader.ReadByte(); err != nil {
+					t.Fatalf("expected a prompt, got %q: %v", out.String(), err)
+				}
+			}
+			drained := make(chan struct{})
+			go func() {
+				io.Copy(io.Discard, reader)
+				close(drained)
+			}()
+
+			for i := 0; i < test.stops; i++ {
+				go app.Stop()
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run() didn't return after Stop()")
+			}
+			<-drained
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+			if n := exits.Load(); n != 1 {
+				t.Errorf("the exit function ran %d times, want once", n)
+			}
+			if app.active.Load() {
+				t.Error("the CLI is still active after Stop()")
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	config := "strictOptions: true\n" + strings.Replace(getConfig, "short: -q\n", "short: -q\n          - label: number\n            short: -n\n            variable:\n              label: number\n              required: true\n", 1)
+	executed := false
+	app, out := newTestApp(t, config, map[string]func(Flags) []byte{"GetAll": func(Flags) []byte {
+		executed = true
+		return []byte("all\n")
+	}}, "")
+	tests := []struct {
+		name     string
+		input    string
+		command  string
+		argument string
+		flags    string
+		err      string
+	}{
+		{"valid", "get all -q -n 5", "get", "all", "{number: set=true value=\"5\", quiet: set=true}", ""},
+		{"valid without options", "get all", "get", "all", "{number: set=false value=\"\", quiet: set=false}", ""},
+		{"unknown command", "got all", "", "", "", `unable to find command "got", did you mean "get"?`},
+		{"no argument", "get", "get", "", "", `invalid use of the "get" command, no valid argument provided`},
+		{"unknown argument", "get none", "get", "", "", `invalid use of the "get" command, no valid argument provided`},
+		{"unknown option", "get all -x", "get", "all", "", `unknown option "-x", did you mean "-q"?`},
+		{"invalid text", "get all -q text", "get", "all", "", `invalid text "text" detected`},
+		{"missing variable", "get all -n", "get", "all", "", `missing variable "number" for option "number"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command, argument, flags, err := app.Parse(test.input)
+			if command.Label != test.command || argument.Label != test.argument {
+				t.Errorf("Parse(%q) = %q, %q, want %q, %q", test.input, command.Label, argument.Label, test.command, test.argument)
+			}
+			if err == nil && test.err != "" || err != nil && err.Error() != test.err {
+				t.Errorf("Parse(%q) error = %v, want %q", test.input, err, test.err)
+			}
+			if err == nil && flags.String() != test.flags {
+				t.Errorf("Parse(%q) flags = %s, want %s", test.input, flags, test.flags)
+			}
+		})
+	}
+	if executed || out.Len() != 0 {
+		t.Errorf("Parse() ran the executable or wrote %q", out.String())
+	}
+}
+
+func TestNormalizeLineEnding(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"get all\r\n", "get all\n"},
+		{"get all\n", "get all\n"},
+		{"get all\r", "get all"},
+		{"get all", "get all"},
+		{"get\rall\r\n", "get\rall\n"},
+		{"\r\n", "\n"},
+	}
+	for _, test := range tests {
+		if got := normalizeLineEnding(test.line); got != test.want {
+			t.Errorf("normalizeLineEnding(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"command", []string{"get all -q"}, "$ all {quiet: set=true}\n$ bye\n"},
+		{"help", []string{"help"}, "$ \nCommands:\n\n\tget all\n\nUse \"<command> help\" for more information about a command.\n$ bye\n"},
+		{"help suffix", []string{"get help"}, "$ \nUsage: get\n\nget all\n\tall \n\nget all -q\n\t-q  \n\n$ bye\n"},
+		{"exit", []string{"exit", "get all"}, "$ bye\n"},
+		{"continuation", []string{"get \\", "all"}, "$ > all {quiet: set=false}\n$ bye\n"},
+		{"quoted", []string{"get all \"-q\""}, "$ all {quiet: set=true}\n$ bye\n"},
+		{"empty line", []string{"", "get all"}, "$ $ all {quiet: set=false}\n$ bye\n"},
+	}
+	for _, test := range tests {
+		for _, ending := range []string{"\n", "\r\n"} {
+			t.Run(fmt.Sprintf("%s %q", test.name, ending), func(t *testing.T) {
+				input := strings.Join(test.lines, ending) + ending
+				app, out := newTestApp(t, "prompt: \"$ \"\n"+getConfig, map[string]func(Flags) []byte{"GetAll": echo("all"), "Exit": output("bye\n")}, input)
+				app.Run()
+				if out.String() != test.want {
+					t.Errorf("Run() wrote %q for %q, want %q", out.String(), input, test.want)
+				}
+			})
+		}
+	}
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Command is the first word or set of consecutive characters.
@@ -9,12 +10,29 @@ type Command struct {
 
 	// When the command is parsed from the input, the command
 	// text must match the label in order to invoke the command.
-	Label string `yaml:"label"`
+	Label string `yaml:"label" json:"label"`
+
+	// (optional) alternative labels which also invoke the command.
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
 
 	// Any arguments that are required for the command.
 	// For a command without any arguments, the argument label
 	// should be an empty string.
-	Arguments []Argument `yaml:"arguments"`
+	Arguments []Argument `yaml:"arguments" json:"arguments"`
+
+	// (optional) options common to every argument of the command,
+	// e.g. (--verbose), which are added to the options of each
+	// argument without child arguments when the config is loaded.
+	// An option of the argument with the same label takes precedence.
+	Options []Option `yaml:"options,omitempty" json:"options,omitempty"`
+
+	// (optional) help message for this command. The first line
+	// is used as the summary of the command in the global help.
+	HelpMsg string `yaml:"help,omitempty" json:"help,omitempty"`
+
+	// (optional) the category of the command, under which the
+	// command is listed in the global help, e.g. "Networking".
+	Category string `yaml:"category,omitempty" json:"category,omitempty"`
 
 	// This function returns a help message for this command.
 	help func(Flags) []byte
@@ -26,3 +44,57 @@ func (cmd Command) createExecutable(arg Argument) func(Flags) []byte {
 		return []byte(fmt.Sprintf("\"%s\" is not configured\n", arg.ExecFunc))
 	}
 }
+
+// names returns the label of the command followed by any aliases
+func (cmd Command) names() []string {
+	return append([]string{cmd.Label}, cmd.Aliases...)
+}
+
+// inheritOptions adds the options of the command to the options of every
+// argument which is invoked itself, i.e. which has no child arguments and
+// is not raw, unless the argument already has an option with the same label.
+// The options added are marked as inherited, so that they are not exported.
+func (cmd Command) inheritOptions() {
+	walkArguments(cmd.Arguments, func(argument *Argument) error {
+		if len(argument.Arguments) > 0 || argument.Raw {
+			return nil
+		}
+		labels := make(map[string]bool, len(argument.Options))
+		for _, option := range argument.Options {
+			labels[option.Label] = true
+		}
+		options := append([]Option{}, argument.Options...)
+		for _, option := range cmd.Options {
+			if !labels[option.Label] {
+				option.inherited = true
+				options = append(options, option)
+			}
+		}
+		argument.Options = options
+		return nil
+	})
+}
+
+// ArgumentLabels returns the label of each argument of the command, in the
+// order the arguments are configured. An argument with an empty label and
+// the child arguments of any argument are not included.
+func (cmd Command) ArgumentLabels() []string {
+	labels := make([]string, 0, len(cmd.Arguments))
+	for _, argument := range cmd.Arguments {
+		if argument.Label != "" {
+			labels = append(labels, argument.Label)
+		}
+	}
+	return labels
+}
+
+// matches returns whether the label matches the label or any alias of the command,
+// ignoring case if caseInsensitive is true.
+func (cmd Command) matches(label string, caseInsensitive bool) bool {
+	for _, name := range cmd.names() {
+		if name == label || (caseInsensitive && strings.EqualFold(name, label)) {
+			return true
+		}
+	}
+	return false
+}
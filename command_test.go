@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const inheritConfig = `
+commands:
+  - label: remote
+    options:
+      - label: verbose
+        short: -v
+        long: --verbose
+    arguments:
+      - label: list
+        execFunc: List
+        options:
+          - label: all
+            short: -a
+      - label: show
+        execFunc: Show
+        options:
+          - label: verbose
+            short: -V
+      - label: add
+        arguments:
+          - label: origin
+            execFunc: Origin
+`
+
+func TestInheritOptions(t *testing.T) {
+	funcs := map[string]func(Flags) []byte{"List": echo("list"), "Show": echo("show"), "Origin": echo("origin")}
+	app, _ := newTestApp(t, inheritConfig, funcs, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"remote list -v -a", "list {all: set=true, verbose: set=true}\n"},
+		{"remote list --verbose", "list {all: set=false, verbose: set=true}\n"},
+		{"remote add origin -v", "origin {verbose: set=true}\n"},
+		{"remote show -V", "show {verbose: set=true}\n"},
+		{"remote show -v", "show {verbose: set=false}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestInheritOptionsConflict(t *testing.T) {
+	_, err := LoadConfigBytes([]byte(testHeader + `
+commands:
+  - label: remote
+    options:
+      - label: verbose
+        short: -v
+    arguments:
+      - label: list
+        options:
+          - label: values
+            short: -v
+`))
+	if err == nil || !strings.Contains(err.Error(), `multiple occurrences of the option short "-v"`) {
+		t.Errorf("expected a conflicting short error, got %v", err)
+	}
+}
+
+func TestInheritOptionsNotExported(t *testing.T) {
+	exported, err := loadTestConfig(t, inheritConfig).Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(exported), "label: verbose"); count != 2 {
+		t.Errorf("expected the verbose option of the command and of show to be exported, got %d in\n%s", count, exported)
+	}
+	reloaded, err := LoadConfigBytes(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := reloaded.Commands[0].Arguments[0]
+	if len(list.Options) != 2 {
+		t.Errorf("expected the exported config to inherit the options once, got %+v", list.Options)
+	}
+}
+
+const commandAliasesConfig = `
+commands:
+  - label: delete
+    aliases: [rm, del]
+    arguments:
+      - label: file
+        execFunc: File
+  - label: list
+    arguments:
+      - label: files
+        execFunc: Files
+`
+
+func TestCommandAliases(t *testing.T) {
+	app, _ := newTestApp(t, commandAliasesConfig, map[string]func(Flags) []byte{"File": output("deleted\n"), "Files": output("listed\n")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"delete file", "deleted\n"},
+		{"rm file", "deleted\n"},
+		{"del file", "deleted\n"},
+		{"list files", "listed\n"},
+		{"rmm file", "unable to find command \"rmm\", did you mean \"rm\"?\n"},
+		{"rm", "invalid use of the \"delete\" command, no valid argument provided\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+	for input, want := range map[string]string{"help": "delete (rm, del)", "delete help": "Aliases: rm, del\n", "rm help": "Aliases: rm, del\n"} {
+		if help := string(app.Execute(input)); !strings.Contains(help, want) {
+			t.Errorf("Execute(%q) = %q, want it to contain %q", input, help, want)
+		}
+	}
+}
+
+func TestCommandAliasesInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases string
+		want    string
+	}{
+		{"label of another command", "[rm, list]", `multiple occurrences of the command label or alias "list"`},
+		{"repeated", "[rm, rm]", `multiple occurrences of the command label or alias "rm"`},
+		{"own label", "[delete]", `multiple occurrences of the command label or alias "delete"`},
+		{"exit command", "[exit]", `command cannot share same label or alias as exit command "exit"`},
+		{"help command", "[help]", `command cannot share same label or alias as help command "help"`},
+		{"empty", `[""]`, `command "delete", empty alias detected`},
+		{"whitespace", `["r m"]`, `command "delete", invalid alias "r m", invalid whitespace characters detected`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := strings.Replace(commandAliasesConfig, "[rm, del]", test.aliases, 1)
+			_, err := LoadConfigBytes([]byte(testHeader + config))
+			if err == nil || err.Error() != test.want {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCommandLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []string
+	}{
+		{"aliases", loadTestConfig(t, commandAliasesConfig), []string{"delete", "rm", "del", "list"}},
+		{"without aliases", loadTestConfig(t, inheritConfig), []string{"remote"}},
+		{"no commands", &Config{}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.config.CommandLabels(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("CommandLabels() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestArgumentLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		command Command
+		want    []string
+	}{
+		{"configured order", loadTestConfig(t, inheritConfig).Commands[0], []string{"list", "show", "add"}},
+		{"empty label and children", Command{Arguments: []Argument{{Label: ""}, {Label: "tasks", Arguments: []Argument{{Label: "all"}}}, {Label: "users"}}}, []string{"tasks", "users"}},
+		{"no arguments", Command{Label: "add"}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.command.ArgumentLabels(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ArgumentLabels() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+// complete completes the last word of the line from the commands, arguments
+// and options defined in the config. If there is a single candidate, the word
+// is completed with the candidate. If there are multiple candidates, the word
+// is completed up to the longest prefix common to all the candidates, which
+// are also returned so that they can be listed.
+func (config *Config) complete(line string) (completed string, candidates []string) {
+
+	// Split the line into the preceding words and the word being completed
+	start := strings.LastIndexAny(line, config.delimiters()) + 1
+	words := config.fields(line[:start])
+	partial := line[start:]
+
+	// Find the candidates for the word being completed
+	if len(words) == 0 {
+		candidates = config.completeCommand(partial)
+	} else {
+		candidates = config.completeArgument(words, partial)
+	}
+	if len(candidates) == 0 {
+		return line, candidates
+	}
+
+	// Complete the word with a single candidate. Options that require
+	// a variable end with a value separator, otherwise a separator is added.
+	if len(candidates) == 1 {
+		completed = line[:start] + candidates[0]
+		if !strings.HasSuffix(completed, "=") {
+			completed += config.separator()
+		}
+		return completed, candidates
+	}
+
+	// Complete the word up to the common prefix of the candidates
+	prefix := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) < len(partial) {
+		prefix = partial
+	}
+	return line[:start] + prefix, candidates
+}
+
+// completeCommand returns the command labels, aliases and built in
+// commands which start with the partial word.
+func (config *Config) completeCommand(partial string) []string {
+	names := append(config.exitCmds(), config.helpCmds()...)
+	if config.ResetCmd != "" {
+		names = append(names, config.ResetCmd)
+	}
+	if config.VersionCmd != "" {
+		names = append(names, config.VersionCmd)
+	}
+	for _, command := range config.Commands {
+		names = append(names, command.names()...)
+	}
+	return config.filterCandidates(names, partial)
+}
+
+// completeArgument returns the argument labels or option names of the
+// command, given by the first of the preceding words, which start with
+// the partial word. Option names are only completed for a partial word
+// starting with a dash (-).
+func (config *Config) completeArgument(words []string, partial string) []string {
+
+	// Find the command being completed
+	var command Command
+	var found bool
+	for _, cmd := range config.Commands {
+		if cmd.matches(words[0], config.CaseInsensitive) {
+			command, found = cmd, true
+			break
+		}
+	}
+	if !found {
+		return []string{}
+	}
+
+	// Find the argument, if there is one, in the preceding words,
+	// descending through any child arguments
+	var argument Argument
+	var resolved bool
+	arguments := command.Arguments
+	rest := " " + strings.Join(words[1:], " ") + " "
+	for {
+		argument, resolved = config.findArgument(arguments, rest)
+		if !resolved || len(argument.Arguments) == 0 {
+			break
+		}
+		arguments = argument.Arguments
+	}
+
+	// Complete the option names of the argument or, if no argument has
+	// been resolved, of any argument at the same level.
+	if strings.HasPrefix(partial, "-") {
+		if resolved {
+			arguments = []Argument{argument}
+		}
+		names := make([]string, 0)
+		for _, arg := range arguments {
+			for _, option := range arg.Options {
+				switch {
+				case option.Long != "" && option.Variable != nil:
+					names = append(names, option.Long+"=")
+				case option.Long != "":
+					names = append(names, option.Long)
+				default:
+					names = append(names, option.Short)
+				}
+			}
+		}
+		return config.filterCandidates(names, partial)
+	}
+
+	// Complete the argument labels and the help command, where the
+	// words of the argument labels are joined with the separator
+	if resolved {
+		return config.filterCandidates(config.helpCmds(), partial)
+	}
+	names := config.helpCmds()
+	for _, arg := range arguments {
+		if arg.Label != "" {
+			names = append(names, strings.ReplaceAll(arg.Label, " ", config.separator()))
+		}
+	}
+	return config.filterCandidates(names, partial)
+}
+
+// findArgument returns the argument with a label in the words, otherwise
+// the argument with an empty label, if there is one, which is unresolved.
+func (config *Config) findArgument(arguments []Argument, words string) (argument Argument, resolved bool) {
+	for _, arg := range arguments {
+		if arg.Label == "" {
+			argument = arg
+			continue
+		}
+		if strings.Contains(config.foldCase(words), " "+config.foldCase(arg.Label)+" ") {
+			return arg, true
+		}
+	}
+	return argument, false
+}
+
+// filterCandidates returns the sorted, unique names which start with the partial word
+func (config *Config) filterCandidates(names []string, partial string) []string {
+	unique := make(map[string]bool)
+	candidates := make([]string, 0)
+	for _, name := range names {
+		if unique[name] || !strings.HasPrefix(config.foldCase(name), config.foldCase(partial)) {
+			continue
+		}
+		unique[name] = true
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const completionConfig = `
+enableCompletion: true
+longValueSeparators: ["="]
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: number
+            long: --number
+            variable:
+              label: number
+          - label: quiet
+            long: --quiet
+          - label: verbose
+            short: -v
+      - label: archived items
+        execFunc: GetArchived
+  - label: git
+    arguments:
+      - label: status
+        execFunc: Status
+`
+
+func TestComplete(t *testing.T) {
+	config := loadTestConfig(t, completionConfig)
+	tests := []struct {
+		line       string
+		completed  string
+		candidates []string
+	}{
+		{"ge", "get ", []string{"get"}},
+		{"g", "g", []string{"get", "git"}},
+		{"h", "help ", []string{"help"}},
+		{"x", "x", []string{}},
+		{"get a", "get a", []string{"all", "archived items"}},
+		{"get al", "get all ", []string{"all"}},
+		{"get ar", "get archived items ", []string{"archived items"}},
+		{"get all --n", "get all --number=", []string{"--number="}},
+		{"get all --q", "get all --quiet ", []string{"--quiet"}},
+		{"get all --", "get all --", []string{"--number=", "--quiet"}},
+		{"get all -", "get all -", []string{"--number=", "--quiet", "-v"}},
+		{"get all --quiet h", "get all --quiet help ", []string{"help"}},
+		{"nothing a", "nothing a", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			completed, candidates := config.complete(test.line)
+			if completed != test.completed || !reflect.DeepEqual(candidates, test.candidates) {
+				t.Errorf("complete(%q) = %q, %q, want %q, %q", test.line, completed, candidates, test.completed, test.candidates)
+			}
+		})
+	}
+}
+
+func TestEditLineCompletion(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		keys    string
+		want    string
+		listed  bool
+	}{
+		{"command", true, "ge\t\r", "get \n", false},
+		{"command and argument", true, "ge\tal\t\r", "get all \n", false},
+		{"option", true, "get all --n\t5\r", "get all --number=5\n", false},
+		{"ambiguous", true, "g\t\r", "g\n", false},
+		{"ambiguous listed", true, "g\t\t\r", "g\n", true},
+		{"disabled", false, "ge\t\r", "ge\n", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := loadTestConfig(t, completionConfig)
+			config.EnableCompletion = test.enabled
+			out := new(strings.Builder)
+			app := NewWithIO(config, strings.NewReader(test.keys), out)
+			got, err := app.editLine()
+			if err != nil || got != test.want {
+				t.Errorf("editLine() of %q = %q, %v, want %q", test.keys, got, err, test.want)
+			}
+			if listed := strings.Contains(out.String(), "\r\nget  git\r\n"); listed != test.listed {
+				t.Errorf("editLine() of %q wrote %q, want the candidates listed %t", test.keys, out.String(), test.listed)
+			}
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v2"
 )
@@ -12,33 +20,207 @@ import (
 type Config struct {
 
 	// The output to the CLI to prompt input from the user.
-	Prompt string `yaml:"prompt"`
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+
+	// (optional) the function called before each input is read to
+	// produce the prompt, which takes precedence over Prompt. This
+	// allows the prompt to reflect the state of the program, e.g.
+	// the current directory. The function must be a func() string,
+	// or a function of the map passed to WithFuncs.
+	PromptFunc string `yaml:"promptFunc,omitempty" json:"promptFunc,omitempty"`
+	promptFunc func() string
 
 	// The commands that are configured.
-	Commands []Command `yaml:"commands"`
+	Commands []Command `yaml:"commands" json:"commands"`
 
 	// The function performed when the CLI is intialised.
 	// The output from this function will appear before
 	// any other output in the CLI.
-	InitFunc string `yaml:"initFunc"`
+	InitFunc string `yaml:"initFunc,omitempty" json:"initFunc,omitempty"`
 	init     func(Flags) []byte
 
 	// The function performed when the CLI is terminated.
 	// This function's output will be the last output to
 	// appear in the CLI before it closes.
-	ExitFunc string `yaml:"exitFunc"`
+	ExitFunc string `yaml:"exitFunc,omitempty" json:"exitFunc,omitempty"`
 	exit     func(Flags) []byte
 
+	// (optional) the function performed before the executable of
+	// every command, with the same flags as the executable. If the
+	// output from this function is not empty, it is used as the
+	// output of the command and the executable is not run.
+	PreFunc string `yaml:"preFunc,omitempty" json:"preFunc,omitempty"`
+	pre     func(Flags) []byte
+
+	// (optional) the function performed after the executable of
+	// every command, with the same flags as the executable. The
+	// output from this function follows the executable output.
+	PostFunc string `yaml:"postFunc,omitempty" json:"postFunc,omitempty"`
+	post     func(Flags) []byte
+
+	// (optional) the function performed for any input which
+	// doesn't start with a command, instead of returning an
+	// error. The input is given by Flags.Positional.
+	FallbackFunc string `yaml:"fallbackFunc,omitempty" json:"fallbackFunc,omitempty"`
+	fallback     func(Flags) []byte
+
 	// The function performed when the user requests help.
 	// This is a built in function that is automatically
 	// created when the config is initialised.
 	help func(Flags) []byte
 
-	// The CLI command used to trigger an exit.
-	ExitCmd string `yaml:"exitCmd"`
+	// The CLI command used to trigger an exit, or a list of
+	// CLI commands which all trigger an exit, e.g. [quit, exit, q].
+	ExitCmd Names `yaml:"exitCmd" json:"exitCmd"`
+
+	// The CLI command used to print a help message, or a list of
+	// CLI commands which all print a help message, e.g. [help, "?"].
+	HelpCmd Names `yaml:"helpCmd" json:"helpCmd"`
+
+	// (optional) the CLI command used to forget the values
+	// remembered for sticky options.
+	ResetCmd string `yaml:"resetCmd,omitempty" json:"resetCmd,omitempty"`
+
+	// (optional) the version of the program, which is printed
+	// by the version command.
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+
+	// (optional) the CLI command used to print the version.
+	// The version command is disabled if this is empty.
+	VersionCmd string `yaml:"versionCmd,omitempty" json:"versionCmd,omitempty"`
+
+	// (optional) the flag which, when given anywhere in the input,
+	// e.g. (--dry-run), prints the command, argument and flags the
+	// input resolves to instead of running the executable. Dry runs
+	// are disabled if this is empty.
+	DryRunFlag string `yaml:"dryRunFlag,omitempty" json:"dryRunFlag,omitempty"`
+
+	// (optional) if true, the resolved flags are written to the
+	// error stream before each executable is run.
+	DebugFlags bool `yaml:"debugFlags,omitempty" json:"debugFlags,omitempty"`
+
+	// (optional) if true, the most recent matching input from the
+	// history is suggested in grey as the user types, which can be
+	// accepted with the right arrow or end key. This is only
+	// available when the CLI is run in a terminal.
+	AutoSuggest bool `yaml:"autoSuggest,omitempty" json:"autoSuggest,omitempty"`
+
+	// (optional) if true, pressing tab completes the command,
+	// argument or option long name being typed, and pressing tab
+	// twice lists the candidates if there are several. This is
+	// only available when the CLI is run in a terminal.
+	EnableCompletion bool `yaml:"enableCompletion,omitempty" json:"enableCompletion,omitempty"`
+
+	// (optional) if true, an error is returned when an option which
+	// is not repeatable is given more than once in the input. By
+	// default, the last occurrence of the option is used.
+	ErrorOnDuplicate bool `yaml:"errorOnDuplicate,omitempty" json:"errorOnDuplicate,omitempty"`
+
+	// (optional) if true, the prompt and the labels in the help
+	// are displayed in colour. This is only available when the
+	// output of the CLI is a terminal.
+	Color bool `yaml:"color,omitempty" json:"color,omitempty"`
+
+	// (optional) if true, ANSI escape sequences such as colours are
+	// removed from the output when the output is not a terminal,
+	// e.g. when it is piped to another program or a file.
+	StripANSIWhenNotTTY bool `yaml:"stripAnsiWhenNotTty,omitempty" json:"stripAnsiWhenNotTty,omitempty"`
+
+	// (optional) if true, output with more lines than the height
+	// of the terminal is paged, waiting for space to show the next
+	// page, enter to show the next line or q to discard the rest.
+	// Output is never paged when the CLI is not run in a terminal.
+	Pager bool `yaml:"pager,omitempty" json:"pager,omitempty"`
+
+	// (optional) the maximum number of inputs kept in the history, the
+	// oldest inputs are discarded once the history is full. If this is
+	// greater than zero, the up and down arrow keys can also be used to
+	// recall inputs from the history when the CLI is run in a terminal.
+	// The history is unlimited if this is zero.
+	HistorySize int `yaml:"historySize,omitempty" json:"historySize,omitempty"`
+
+	// (optional) the prefix used to re-execute inputs from the history.
+	// With the prefix "!", "!!" repeats the last input and "!n" repeats
+	// the nth input of the session, which is not found once it has been
+	// discarded from the history. History expansion is disabled if this
+	// is empty.
+	HistoryExpansion string `yaml:"historyExpansion,omitempty" json:"historyExpansion,omitempty"`
 
-	// The CLI command used to print a help message.
-	HelpCmd string `yaml:"helpCmd"`
+	// (optional) the width the help messages are wrapped to. By
+	// default, this is the width of the terminal, or 80 if the
+	// output of the CLI is not a terminal.
+	HelpWidth int `yaml:"helpWidth,omitempty" json:"helpWidth,omitempty"`
+
+	// (optional) the maximum length of the command summaries
+	// listed in the global help, longer summaries are truncated.
+	// The summaries are not truncated if this is zero.
+	HelpSummaryLength int `yaml:"helpSummaryLength,omitempty" json:"helpSummaryLength,omitempty"`
+
+	// (optional) if true, the commands in the global help and the
+	// options in the help of each argument are listed alphabetically.
+	// By default, they are listed in the order they are defined.
+	SortHelp bool `yaml:"sortHelp,omitempty" json:"sortHelp,omitempty"`
+
+	// (optional) the characters which separate the command, the
+	// argument and the options in the input, and which are trimmed
+	// from the input, e.g. " \t," to also separate on commas.
+	// Defaults to whitespace, namely spaces, tabs and line endings.
+	Delimiters string `yaml:"delimiters,omitempty" json:"delimiters,omitempty"`
+
+	// (optional) the primary delimiter, used to join the parts of the
+	// input where a delimiter is required, e.g. when joining continued
+	// lines or completing a word. Runs of delimiters in the input are
+	// always collapsed, so tokens can be separated by any number of
+	// delimiters. The separator is always one of the delimiters, and
+	// defaults to the first of the delimiters.
+	Separator string `yaml:"separator,omitempty" json:"separator,omitempty"`
+
+	// (optional) the separators accepted between the name and the
+	// value of a long option, e.g. ":" for (--level:debug).
+	// Defaults to only accepting the equals sign (=).
+	LongValueSeparators []string `yaml:"longValueSeparators,omitempty" json:"longValueSeparators,omitempty"`
+
+	// (optional) if true, the methods for the execFuncs are only
+	// looked up from the program the first time they are invoked,
+	// which speeds up the startup for large configs. Use App.Verify
+	// to check all the methods exist up front.
+	LazyExec bool `yaml:"lazyExec,omitempty" json:"lazyExec,omitempty"`
+
+	// (optional) the writer which receives a record of every command
+	// executed, as a line of JSON with the time, the command label, the
+	// argument label, the values of the options set and the length of
+	// the output. The values of secret options are masked.
+	AuditWriter io.Writer `yaml:"-" json:"-"`
+
+	// (optional) functions applied to the input, in order, before it
+	// is parsed, which can rewrite the input, e.g. to strip a prefix
+	// or expand an alias, or veto the input by returning false, in
+	// which case the input is not processed and there is no output.
+	InputMiddleware []func(input string) (string, bool) `yaml:"-" json:"-"`
+
+	// (optional) if true, an error is returned for any option in the
+	// input which doesn't match one of the options of the argument.
+	// By default, such options are ignored.
+	StrictOptions bool `yaml:"strictOptions,omitempty" json:"strictOptions,omitempty"`
+
+	// (optional) if true, every argument without child arguments
+	// must have an execFunc, otherwise an error listing the arguments
+	// without one is returned when the methods are applied. By default,
+	// such arguments output that the command is not configured.
+	RequireAllExecFuncs bool `yaml:"requireAllExecFuncs,omitempty" json:"requireAllExecFuncs,omitempty"`
+
+	// (optional) if true, a single line synopsis of the usage of
+	// the command or argument follows the error for invalid input.
+	UsageOnError bool `yaml:"usageOnError,omitempty" json:"usageOnError,omitempty"`
+
+	// (optional) if true, command and argument labels are matched
+	// ignoring case, e.g. "LIST" invokes the "list" command.
+	CaseInsensitive bool `yaml:"caseInsensitive,omitempty" json:"caseInsensitive,omitempty"`
+
+	// (optional) if true, the CLI continues interactively after
+	// running the input given by the program arguments to RunArgs,
+	// otherwise the CLI exits once the input has been run.
+	InteractiveAfterArgs bool `yaml:"interactiveAfterArgs,omitempty" json:"interactiveAfterArgs,omitempty"`
 }
 
 // LoadConfig extracts the config from the given yaml
@@ -53,52 +235,504 @@ func LoadConfig(filename string) (config *Config, err error) {
 		return config, err
 	}
 
-	// Attempt to unmarshal the yaml file into config
+	return LoadConfigBytes(yamlFile)
+}
+
+// LoadConfigFromReader extracts the config from the yaml
+// read from r and unmarshals it into a Config.
+// Any errors reading or unmarshaling the yaml will be
+// returned.
+func LoadConfigFromReader(r io.Reader) (config *Config, err error) {
+
+	// Attempt to read the yaml
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return config, err
+	}
+
+	return LoadConfigBytes(data)
+}
+
+// LoadConfigBytes extracts the config from the yaml data
+// and unmarshals it into a Config, e.g. for a config that
+// has been embedded in the program with go:embed.
+// Any errors unmarshaling the yaml will be returned.
+func LoadConfigBytes(data []byte) (config *Config, err error) {
+
+	// Attempt to unmarshal the yaml into config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+	if config == nil {
+		config = &Config{}
+	}
+
+	return config, config.setup()
+}
+
+// LoadConfigJSON extracts the config from the given json
+// file and unmarshals it into a Config, using the same
+// schema and validation as LoadConfig.
+// Any errors reading the file or unmarshaling the file
+// will be returned.
+func LoadConfigJSON(filename string) (config *Config, err error) {
+
+	// Attempt to read the file
+	jsonFile, err := os.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+
+	// Attempt to unmarshal the json file into config
+	if err := json.Unmarshal(jsonFile, &config); err != nil {
+		return config, err
+	}
+	if config == nil {
+		config = &Config{}
+	}
+
+	return config, config.setup()
+}
+
+// LoadConfigDir extracts the config from the base yaml file, along
+// with additional commands from every yaml file (.yaml or .yml) and
+// json file (.json) in the commands directory, and unmarshals them
+// into a single Config.
+// The base config should contain the prompt, the exit and help
+// commands and any other global options. Each file in the commands
+// directory should contain a list of "commands", using the same
+// schema as the base config. The files are loaded in alphabetical
+// order, so that the order of the commands in help is deterministic.
+// Any errors reading or unmarshaling the files will be returned.
+func LoadConfigDir(baseConfig, commandsDir string) (config *Config, err error) {
+
+	// Attempt to read the base file
+	yamlFile, err := os.ReadFile(baseConfig)
+	if err != nil {
+		return config, err
+	}
+
+	// Attempt to unmarshal the base yaml file into config
 	if err := yaml.Unmarshal(yamlFile, &config); err != nil {
 		return config, err
 	}
+	if config == nil {
+		config = &Config{}
+	}
+
+	// Find the yaml and json files in the commands directory, in alphabetical order
+	filenames := make([]string, 0)
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(commandsDir, pattern))
+		if err != nil {
+			return config, err
+		}
+		filenames = append(filenames, matches...)
+	}
+	sort.Strings(filenames)
+
+	// Attempt to unmarshal the commands from each file
+	for _, filename := range filenames {
+		fragmentFile, err := os.ReadFile(filename)
+		if err != nil {
+			return config, err
+		}
+		var fragment struct {
+			Commands []Command `yaml:"commands" json:"commands"`
+		}
+		unmarshal := yaml.Unmarshal
+		if filepath.Ext(filename) == ".json" {
+			unmarshal = json.Unmarshal
+		}
+		if err := unmarshal(fragmentFile, &fragment); err != nil {
+			return config, fmt.Errorf("file \"%s\", %s", filename, err)
+		}
+		config.Commands = append(config.Commands, fragment.Commands...)
+	}
+
+	return config, config.setup()
+}
+
+// setup validates the config and generates the placeholder and help commands.
+func (config *Config) setup() error {
 
 	// Validation check on the exit command
-	if config.ExitCmd == "" {
-		return config, fmt.Errorf("missing/empty exit command \"exitCmd\"")
+	if len(config.ExitCmd) == 0 {
+		return fmt.Errorf("missing/empty exit command \"exitCmd\"")
 	}
 
 	// Validation check on the help command
-	if config.HelpCmd == "" {
-		return config, fmt.Errorf("missing/empty help command \"helpCmd\"")
+	if len(config.HelpCmd) == 0 {
+		return fmt.Errorf("missing/empty help command \"helpCmd\"")
+	}
+
+	// Validation check on each of the exit and help commands
+	builtins := make(map[string]bool)
+	for _, name := range append(config.exitCmds(), config.helpCmds()...) {
+		if name == "" || strings.ContainsAny(name, config.delimiters()) {
+			return fmt.Errorf("invalid exit or help command \"%s\"", name)
+		}
+		if _, alreadyExists := builtins[name]; alreadyExists {
+			return fmt.Errorf("multiple occurrences of the exit or help command \"%s\"", name)
+		}
+		builtins[name] = true
+	}
+
+	// Validation check on the version command
+	if config.VersionCmd != "" {
+		if config.Version == "" {
+			return fmt.Errorf("missing/empty version \"version\" for the version command \"%s\"", config.VersionCmd)
+		}
+		if config.isExitCmd(config.VersionCmd) || config.isHelpCmd(config.VersionCmd) || config.VersionCmd == config.ResetCmd {
+			return fmt.Errorf("version command \"%s\" cannot share same label as the exit, help or reset command", config.VersionCmd)
+		}
 	}
 
 	// Config needs to have at least one command
 	if len(config.Commands) == 0 {
-		return config, fmt.Errorf("missing/empty commands \"commands\"")
+		return fmt.Errorf("missing/empty commands \"commands\"")
 	}
 
-	// Validation check on the commands
-	for _, command := range config.Commands {
-		if command.Label == config.ExitCmd {
-			return config, fmt.Errorf("command cannot share same label as exit command \"%s\"", config.ExitCmd)
+	// Validation check on the long value separators
+	for _, separator := range config.LongValueSeparators {
+		if separator == "" || strings.ContainsAny(separator, config.delimiters()) {
+			return fmt.Errorf("invalid long value separator \"%s\"", separator)
 		}
-		if command.Label == config.HelpCmd {
-			return config, fmt.Errorf("command cannot share same label as help command \"%s\"", config.HelpCmd)
+	}
+
+	// Validation check on the dry run flag, which must look like an option
+	if config.DryRunFlag != "" && (!strings.HasPrefix(config.DryRunFlag, "-") || strings.ContainsAny(config.DryRunFlag, config.delimiters()+"\"'")) {
+		return fmt.Errorf("invalid dry run flag \"%s\", must start with a dash (-) without any delimiters or quotes", config.DryRunFlag)
+	}
+
+	// Validation check on the delimiters, which cannot be part of an option or quote
+	if strings.ContainsAny(config.Delimiters, "-\"'\\") {
+		return fmt.Errorf("invalid delimiters \"%s\", dashes, quotes and backslashes cannot be delimiters", config.Delimiters)
+	}
+	if config.Separator != "" {
+		if utf8.RuneCountInString(config.Separator) != 1 {
+			return fmt.Errorf("invalid separator \"%s\", must be a single character", config.Separator)
 		}
+		if strings.ContainsAny(config.Separator, "-\"'\\") {
+			return fmt.Errorf("invalid separator \"%s\", dashes, quotes and backslashes cannot be delimiters", config.Separator)
+		}
+	}
+
+	// Validation check on the commands, once the options of each command
+	// have been inherited by its arguments
+	labels := make(map[string]bool)
+	for _, command := range config.Commands {
+		command.inheritOptions()
 		if err := command.validate(); err != nil {
-			return config, err
+			return err
+		}
+
+		// Command labels and aliases must not repeat or collide with the built in commands
+		for _, name := range command.names() {
+			if strings.ContainsAny(name, config.delimiters()) {
+				return fmt.Errorf("invalid command label or alias \"%s\", delimiters detected", name)
+			}
+			if _, alreadyExists := labels[config.foldCase(name)]; alreadyExists {
+				return fmt.Errorf("multiple occurrences of the command label or alias \"%s\"", name)
+			}
+			labels[config.foldCase(name)] = true
+			if config.isExitCmd(name) {
+				return fmt.Errorf("command cannot share same label or alias as exit command \"%s\"", name)
+			}
+			if config.isHelpCmd(name) {
+				return fmt.Errorf("command cannot share same label or alias as help command \"%s\"", name)
+			}
+			if config.ResetCmd != "" && name == config.ResetCmd {
+				return fmt.Errorf("command cannot share same label or alias as reset command \"%s\"", config.ResetCmd)
+			}
+			if config.VersionCmd != "" && name == config.VersionCmd {
+				return fmt.Errorf("command cannot share same label or alias as version command \"%s\"", config.VersionCmd)
+			}
+		}
+
+		// Validation check on the arguments against the config
+		if err := config.validateArguments(command, command.Arguments); err != nil {
+			return err
 		}
 	}
 
-	// Generate placeholder and help commands
-	config.init = func(Flags) []byte { return []byte("") }
-	config.exit = func(Flags) []byte { return []byte("") }
+	// Generate placeholder and help commands, keeping any functions
+	// already mapped with WithFuncs
+	if config.init == nil {
+		config.init = func(Flags) []byte { return []byte("") }
+	}
+	if config.exit == nil {
+		config.exit = func(Flags) []byte { return []byte("") }
+	}
 	for i, command := range config.Commands {
-		for j, argument := range command.Arguments {
-			config.Commands[i].Arguments[j].executable = command.createExecutable(argument)
-			config.Commands[i].Arguments[j].help = command.createArgHelp(argument)
-		}
+		command.setupArguments(command.Label, "", command.Arguments)
 		config.Commands[i].help = command.createHelp()
 	}
 	config.help = config.createHelp()
 
-	return config, nil
+	return nil
+}
+
+// validateArguments performs the validation checks on the arguments of
+// the command, and recursively their child arguments, which depend on
+// the config.
+func (config *Config) validateArguments(command Command, arguments []Argument) error {
+
+	// Argument labels must not differ only by case, if case insensitive
+	if config.CaseInsensitive {
+		argLabels := make(map[string]bool)
+		for _, argument := range arguments {
+			if _, alreadyExists := argLabels[config.foldCase(argument.Label)]; alreadyExists {
+				return fmt.Errorf("command \"%s\", multiple occurrences of the argument label \"%s\" ignoring case", command.Label, argument.Label)
+			}
+			argLabels[config.foldCase(argument.Label)] = true
+		}
+	}
+
+	// Argument labels must not end with the help command, as the help command
+	// at the end of the input always requests help, or be the exit command,
+	// which would be mistaken for exiting the CLI.
+	for _, argument := range arguments {
+		for _, helpCmd := range config.helpCmds() {
+			rest := strings.TrimSuffix(argument.Label, helpCmd)
+			if argument.Label == helpCmd || (rest != argument.Label && strings.ContainsAny(rest[len(rest)-1:], " "+config.delimiters())) {
+				return fmt.Errorf("command \"%s\", argument label \"%s\" cannot end with the help command \"%s\"", command.Label, argument.Label, helpCmd)
+			}
+		}
+		if config.isExitCmd(argument.Label) {
+			return fmt.Errorf("command \"%s\", argument cannot share same label as exit command \"%s\"", command.Label, argument.Label)
+		}
+	}
+
+	// Option shorts and longs must not be the help or exit command, for the same reasons
+	for _, argument := range arguments {
+		for _, option := range argument.Options {
+			for _, name := range []string{option.Short, option.Long} {
+				if config.isHelpCmd(name) {
+					return fmt.Errorf("command \"%s\", argument \"%s\", option \"%s\" cannot share same name as help command \"%s\"", command.Label, argument.Label, option.Label, name)
+				}
+				if config.isExitCmd(name) {
+					return fmt.Errorf("command \"%s\", argument \"%s\", option \"%s\" cannot share same name as exit command \"%s\"", command.Label, argument.Label, option.Label, name)
+				}
+			}
+		}
+	}
+
+	// Option longs must not contain a long value separator
+	for _, argument := range arguments {
+		for _, option := range argument.Options {
+			for _, separator := range config.longValueSeparators() {
+				if option.Long != "" && strings.Contains(option.Long, separator) {
+					return fmt.Errorf("command \"%s\", argument \"%s\", invalid option long \"%s\", contains the long value separator \"%s\"", command.Label, argument.Label, option.Long, separator)
+				}
+			}
+		}
+		if err := config.validateArguments(command, argument.Arguments); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupArguments generates the placeholder and help commands for the arguments
+// and, recursively, their child arguments. The prefix is the command label and
+// the labels of any parent arguments, and the path the labels of any parent
+// arguments only. A placeholder doesn't replace an executable already mapped.
+func (cmd Command) setupArguments(prefix, path string, arguments []Argument) {
+	for i, argument := range arguments {
+		arguments[i].path = strings.TrimLeft(path+" "+argument.Label, " ")
+		if argument.executable == nil {
+			arguments[i].executable = cmd.createExecutable(argument)
+		}
+		arguments[i].help = createArgHelp(prefix, argument)
+		cmd.setupArguments(strings.TrimRight(prefix+" "+argument.Label, " "), arguments[i].path, argument.Arguments)
+	}
+}
+
+// CommandLabels returns the label of each command of the config, followed by
+// any aliases of the command, in the order the commands are configured. The
+// built in commands, such as the exit and help commands, are not included.
+func (config *Config) CommandLabels() []string {
+	labels := make([]string, 0, len(config.Commands))
+	for _, command := range config.Commands {
+		labels = append(labels, command.names()...)
+	}
+	return labels
+}
+
+// exitCmds returns each of the exit commands
+func (config *Config) exitCmds() []string {
+	return append([]string{}, config.ExitCmd...)
+}
+
+// helpCmds returns each of the help commands
+func (config *Config) helpCmds() []string {
+	return append([]string{}, config.HelpCmd...)
+}
+
+// isExitCmd returns whether the input is one of the exit commands
+func (config *Config) isExitCmd(input string) bool {
+	for _, name := range config.exitCmds() {
+		if input == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isHelpCmd returns whether the input is one of the help commands
+func (config *Config) isHelpCmd(input string) bool {
+	for _, name := range config.helpCmds() {
+		if input == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Names is a list of names, which is given in the config as either a
+// single name, e.g. (exitCmd: exit), or a list, e.g. (exitCmd: [quit, exit]).
+type Names []string
+
+// UnmarshalYAML unmarshals the names from either a single name or a list.
+func (names *Names) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*names = Names{name}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*names = list
+	return nil
+}
+
+// MarshalYAML marshals a single name as the name, otherwise as a list.
+func (names Names) MarshalYAML() (interface{}, error) {
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	return []string(names), nil
+}
+
+// UnmarshalJSON unmarshals the names from either a single name or a list.
+func (names *Names) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*names = Names{name}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*names = list
+	return nil
+}
+
+// MarshalJSON marshals a single name as the name, otherwise as a list.
+func (names Names) MarshalJSON() ([]byte, error) {
+	if len(names) == 1 {
+		return json.Marshal(names[0])
+	}
+	return json.Marshal([]string(names))
+}
+
+// longValueSeparators returns the separators accepted between the name and
+// the value of a long option, namely the configured separators, otherwise
+// only the equals sign (=).
+func (config *Config) longValueSeparators() []string {
+	if len(config.LongValueSeparators) == 0 {
+		return []string{"="}
+	}
+	return config.LongValueSeparators
+}
+
+// delimiters returns the characters which separate the tokens of the input,
+// which always include the separator.
+func (config *Config) delimiters() string {
+	delimiters := config.Delimiters
+	if delimiters == "" {
+		delimiters = whitespaceCharacters
+	}
+	if !strings.Contains(delimiters, config.Separator) {
+		delimiters = config.Separator + delimiters
+	}
+	return delimiters
+}
+
+// lineDelimiters returns the characters trimmed from the ends of a line
+// of input, namely the delimiters and the line ending characters.
+func (config *Config) lineDelimiters() string {
+	return config.delimiters() + "\r\n"
+}
+
+// separator returns the delimiter used to join parts of the input,
+// namely the separator if configured, otherwise the first of the delimiters.
+func (config *Config) separator() string {
+	if config.Separator != "" {
+		return config.Separator
+	}
+	for _, r := range config.delimiters() {
+		return string(r)
+	}
+	return " "
+}
+
+// fields splits the input into the fields separated by the delimiters
+func (config *Config) fields(input string) []string {
+	delimiters := config.delimiters()
+	return strings.FieldsFunc(input, func(r rune) bool {
+		return strings.ContainsRune(delimiters, r)
+	})
+}
+
+// foldCase returns the label in lower case if the config is case
+// insensitive, so that labels differing only by case are equal.
+// Otherwise the label is returned unchanged.
+func (config *Config) foldCase(label string) string {
+	if config.CaseInsensitive {
+		return strings.ToLower(label)
+	}
+	return label
+}
+
+// Export serializes the config back to YAML, using the same schema as
+// the config files loaded by LoadConfig. Only the configurable fields
+// are exported, the methods applied from a program are omitted.
+func (config *Config) Export() ([]byte, error) {
+	exported := *config
+	exported.Commands = make([]Command, len(config.Commands))
+	for i, command := range config.Commands {
+		command.Arguments = exportArguments(command.Arguments)
+		exported.Commands[i] = command
+	}
+	return yaml.Marshal(&exported)
+}
+
+// exportArguments returns a copy of the arguments, and recursively their
+// child arguments, without the options inherited from the command, which
+// are only exported as the options of the command.
+func exportArguments(arguments []Argument) []Argument {
+	if arguments == nil {
+		return nil
+	}
+	exported := make([]Argument, len(arguments))
+	for i, argument := range arguments {
+		var options []Option
+		for _, option := range argument.Options {
+			if !option.inherited {
+				options = append(options, option)
+			}
+		}
+		argument.Options = options
+		argument.Arguments = exportArguments(argument.Arguments)
+		exported[i] = argument
+	}
+	return exported
 }
 
 // WithProgram maps the execFuncs defined in the config
@@ -108,6 +742,11 @@ func LoadConfig(filename string) (config *Config, err error) {
 // (func(Flags) []byte), then an error will be returned.
 func (config *Config) withProgram(program interface{}) (err error) {
 
+	// Check every argument has an execFunc, if required.
+	if err := config.checkExecFuncs(); err != nil {
+		return err
+	}
+
 	// Apply the init method.
 	config.init, err = getExecutable(program, config.InitFunc)
 	if err != nil {
@@ -120,44 +759,271 @@ func (config *Config) withProgram(program interface{}) (err error) {
 		return err
 	}
 
+	// Apply the prompt method, if any.
+	if config.PromptFunc != "" {
+		config.promptFunc, err = getPromptFunc(program, config.PromptFunc)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Apply the pre, post and fallback methods, if any.
+	for _, hook := range []struct {
+		funcName string
+		action   *func(Flags) []byte
+	}{{config.PreFunc, &config.pre}, {config.PostFunc, &config.post}, {config.FallbackFunc, &config.fallback}} {
+		if hook.funcName == "" {
+			continue
+		}
+		if config.LazyExec {
+			*hook.action = lazyExecutable(program, hook.funcName)
+			continue
+		}
+		*hook.action, err = getExecutable(program, hook.funcName)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Apply the argument methods.
-	for i, command := range config.Commands {
-		for j, argument := range command.Arguments {
-			if argument.ExecFunc != "" {
-				config.Commands[i].Arguments[j].executable, err = getExecutable(program, argument.ExecFunc)
-				if err != nil {
-					return err
-				}
+	for _, command := range config.Commands {
+		err = walkArguments(command.Arguments, func(argument *Argument) (err error) {
+			if argument.ExecFunc == "" {
+				return nil
+			}
+			if config.LazyExec {
+				argument.executable = lazyExecutable(program, argument.ExecFunc)
+				return nil
+			}
+			argument.executable, err = getExecutable(program, argument.ExecFunc)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithFuncs maps the init, exit, pre, post and fallback functions and the
+// execFuncs defined in the config to the functions with the same name in
+// funcs, so that closures can be used as executables without a program. If funcs
+// doesn't have a function with the same name, an error will be returned.
+// The prompt function is also mapped to the function with the same name in
+// funcs, and the output of the function is used as the prompt.
+func (config *Config) WithFuncs(funcs map[string]func(Flags) []byte) (err error) {
+
+	// Check every argument has an execFunc, if required.
+	if err := config.checkExecFuncs(); err != nil {
+		return err
+	}
+
+	// Apply the init and exit functions, and the pre, post and fallback functions, if any.
+	for _, hook := range []struct {
+		funcName string
+		action   *func(Flags) []byte
+		optional bool
+	}{
+		{config.InitFunc, &config.init, false},
+		{config.ExitFunc, &config.exit, false},
+		{config.PreFunc, &config.pre, true},
+		{config.PostFunc, &config.post, true},
+		{config.FallbackFunc, &config.fallback, true},
+	} {
+		if hook.funcName == "" && hook.optional {
+			continue
+		}
+		*hook.action, err = getFunc(funcs, hook.funcName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Apply the prompt function, if any.
+	if config.PromptFunc != "" {
+		prompt, err := getFunc(funcs, config.PromptFunc)
+		if err != nil {
+			return err
+		}
+		config.promptFunc = func() string { return string(prompt(Flags{})) }
+	}
+
+	// Apply the argument functions.
+	for _, command := range config.Commands {
+		err = walkArguments(command.Arguments, func(argument *Argument) (err error) {
+			if argument.ExecFunc == "" {
+				return nil
 			}
+			argument.executable, err = getFunc(funcs, argument.ExecFunc)
+			return err
+		})
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// checkExecFuncs returns an error listing every argument without child
+// arguments which doesn't have an execFunc, if RequireAllExecFuncs is set.
+func (config *Config) checkExecFuncs() error {
+	if !config.RequireAllExecFuncs {
+		return nil
+	}
+	missing := make([]string, 0)
+	for _, command := range config.Commands {
+		walkArguments(command.Arguments, func(argument *Argument) error {
+			if argument.ExecFunc == "" && len(argument.Arguments) == 0 {
+				missing = append(missing, fmt.Sprintf("\"%s\"", strings.TrimRight(command.Label+" "+argument.path, " ")))
+			}
+			return nil
+		})
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no execFunc configured for the arguments %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// getFunc returns the function from funcs with the given funcName,
+// or an error if there is no such function.
+func getFunc(funcs map[string]func(Flags) []byte, funcName string) (func(Flags) []byte, error) {
+	action, ok := funcs[funcName]
+	if !ok || action == nil {
+		return nil, fmt.Errorf("unable to find function \"%s\"", funcName)
+	}
+	return action, nil
+}
+
+// verify checks that every execFunc defined in the config
+// maps to a method with the same name in program, returning
+// an error for the first one that doesn't.
+func (config *Config) verify(program interface{}) error {
+	for _, funcName := range []string{config.InitFunc, config.ExitFunc} {
+		if _, err := getExecutable(program, funcName); err != nil {
+			return err
+		}
+	}
+	if config.PromptFunc != "" {
+		if _, err := getPromptFunc(program, config.PromptFunc); err != nil {
+			return err
+		}
+	}
+	for _, funcName := range []string{config.PreFunc, config.PostFunc, config.FallbackFunc} {
+		if funcName == "" {
+			continue
+		}
+		if _, err := getExecutable(program, funcName); err != nil {
+			return err
+		}
+	}
+	for _, command := range config.Commands {
+		err := walkArguments(command.Arguments, func(argument *Argument) error {
+			if argument.ExecFunc == "" {
+				return nil
+			}
+			_, err := getExecutable(program, argument.ExecFunc)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lazyExecutable returns an executable which only looks up the method from
+// the program the first time it is run, caching the method afterwards.
+// If the method cannot be found, the executable outputs the error instead.
+func lazyExecutable(program interface{}, funcName string) func(Flags) []byte {
+	var once sync.Once
+	var action func(Flags) []byte
+	var err error
+	return func(flags Flags) []byte {
+		once.Do(func() {
+			action, err = getExecutable(program, funcName)
+		})
+		if err != nil {
+			return []byte(fmt.Sprintf("%v\n", err))
+		}
+		return action(flags)
+	}
+}
+
 // getExecutable attempts to return the method from the program from the given funcName.
-// If the method doesn't exist or is not of the correct type (func(Flags) []byte), an
-// error will be returned.
+// If the method doesn't exist or is not of an executable type, either func(Flags) []byte,
+// the streaming func(Flags, io.Writer) []byte, func(context.Context, Flags) []byte,
+// func(Flags) ([]byte, error) or func(Flags, *Session) []byte, an error will be returned.
 func getExecutable(program interface{}, funcName string) (action func(Flags) []byte, err error) {
+	method, err := getMethod(program, funcName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method := method.Interface().(type) {
+
+	// Plain methods are used as they are
+	case func(Flags) []byte:
+		return method, nil
+
+	// Streaming methods write to the stream provided in the flags
+	case func(Flags, io.Writer) []byte:
+		return func(flags Flags) []byte {
+			return method(flags, flags.Stream())
+		}, nil
 
-	// Create and run a panic-safe func. This func will attempt to do the following:
-	// - Use the reflect package to get the action method called funcName.
-	// - Convert that reflecr.Value into an interface
-	// - Type cast the interface into the correct type for an action method (func(Flags) []byte)
-	// If any of these stages fail, a panic will be raised. This is caught by the recover()
-	// in the defer statment, so that if a panic occurres, we will not terminate.
-	// If the panic is caught, this function will return <nil> as the return value.
-	action = func() func(Flags) []byte {
-		defer func() {
-			recover()
-		}()
-		return reflect.ValueOf(program).MethodByName(funcName).Interface().(func(Flags) []byte)
-	}()
-
-	// Raise an error if enable to find the method funcName
-	if action == nil {
-		return action, fmt.Errorf("unable to find method \"%s\" for type \"%s\"", funcName, reflect.TypeOf(program))
+	// Methods returning an error report it through the flags
+	case func(Flags) ([]byte, error):
+		return func(flags Flags) []byte {
+			output, err := method(flags)
+			if err != nil && flags.fail != nil {
+				flags.fail(err)
+			}
+			return output
+		}, nil
+
+	// Context methods observe the context provided in the flags
+	case func(context.Context, Flags) []byte:
+		return func(flags Flags) []byte {
+			return method(flags.Context(), flags)
+		}, nil
+
+	// Session methods interact with the user through the session provided in the flags
+	case func(Flags, *Session) []byte:
+		return func(flags Flags) []byte {
+			return method(flags, flags.Session())
+		}, nil
 	}
 
-	return action, nil
+	// Raise an error if the method is not of an executable type
+	return nil, fmt.Errorf("method \"%s\" for type \"%s\" has invalid type \"%s\", must be func(Flags) []byte, func(Flags, io.Writer) []byte, func(context.Context, Flags) []byte, func(Flags) ([]byte, error) or func(Flags, *Session) []byte", funcName, reflect.TypeOf(program), method.Type())
+}
+
+// getPromptFunc attempts to return the method from the program from the given funcName.
+// If the method doesn't exist or is not of the type func() string, an error will be returned.
+func getPromptFunc(program interface{}, funcName string) (func() string, error) {
+	method, err := getMethod(program, funcName)
+	if err != nil {
+		return nil, err
+	}
+	promptFunc, ok := method.Interface().(func() string)
+	if !ok {
+		return nil, fmt.Errorf("method \"%s\" for type \"%s\" has invalid type \"%s\", must be func() string", funcName, reflect.TypeOf(program), method.Type())
+	}
+	return promptFunc, nil
+}
+
+// getMethod returns the exported method of the program with the given funcName,
+// or an error if the program doesn't have such a method.
+func getMethod(program interface{}, funcName string) (method reflect.Value, err error) {
+	value := reflect.ValueOf(program)
+	if value.IsValid() {
+		method = value.MethodByName(funcName)
+	}
+	if !method.IsValid() {
+		return method, fmt.Errorf("unable to find method \"%s\" for type \"%v\"", funcName, reflect.TypeOf(program))
+	}
+	return method, nil
 }
@@ -0,0 +1,809 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const aliasesConfig = `
+exitCmd: [quit, exit, q]
+helpCmd: [help, "?"]
+initFunc: Init
+exitFunc: Exit
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+`
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		json string
+		want Names
+	}{
+		{"single", `exit`, `"exit"`, Names{"exit"}},
+		{"list", `[quit, exit, q]`, `["quit","exit","q"]`, Names{"quit", "exit", "q"}},
+		{"single item list", `[exit]`, `["exit"]`, Names{"exit"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fromYAML, fromJSON Names
+			if err := yaml.Unmarshal([]byte(test.yaml), &fromYAML); err != nil || !reflect.DeepEqual(fromYAML, test.want) {
+				t.Errorf("yaml %s = %q, %v, want %q", test.yaml, fromYAML, err, test.want)
+			}
+			if err := json.Unmarshal([]byte(test.json), &fromJSON); err != nil || !reflect.DeepEqual(fromJSON, test.want) {
+				t.Errorf("json %s = %q, %v, want %q", test.json, fromJSON, err, test.want)
+			}
+
+			// Marshalling the names and unmarshalling them again is stable
+			var reloaded Names
+			data, err := yaml.Marshal(test.want)
+			if err != nil || yaml.Unmarshal(data, &reloaded) != nil || !reflect.DeepEqual(reloaded, test.want) {
+				t.Errorf("yaml round trip of %q = %q, %v", test.want, reloaded, err)
+			}
+			data, err = json.Marshal(test.want)
+			if err != nil || json.Unmarshal(data, &reloaded) != nil || !reflect.DeepEqual(reloaded, test.want) {
+				t.Errorf("json round trip of %q = %q, %v", test.want, reloaded, err)
+			}
+		})
+	}
+	var names Names
+	if err := yaml.Unmarshal([]byte(`{exit: q}`), &names); err == nil {
+		t.Errorf("expected an error unmarshalling a map as names, got %q", names)
+	}
+}
+
+func TestExitAliases(t *testing.T) {
+	for _, input := range []string{"quit", "exit", "q"} {
+		t.Run(input, func(t *testing.T) {
+			app, _ := newTestApp(t, aliasesConfig, map[string]func(Flags) []byte{"GetAll": output(""), "Exit": output("bye\n")}, "")
+			if got := string(app.Execute(input)); got != "bye\n" {
+				t.Errorf("Execute(%q) = %q, want the exit output", input, got)
+			}
+			if app.active.Load() {
+				t.Errorf("Execute(%q) didn't exit", input)
+			}
+		})
+	}
+}
+
+func TestHelpAliases(t *testing.T) {
+	app, _ := newTestApp(t, aliasesConfig, map[string]func(Flags) []byte{"GetAll": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"?", "help"},
+		{"get ?", "get help"},
+		{"get all ?", "get all help"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			want := string(app.Execute(test.want))
+			if got := string(app.Execute(test.input)); got != want || got == "" {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, want)
+			}
+			if err := app.Check(test.input); err != nil {
+				t.Errorf("Check(%q) = %v", test.input, err)
+			}
+		})
+	}
+}
+
+func TestAliasesInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		label  string
+		want   string
+	}{
+		{"command is exit alias", "exitCmd: [quit, q]\nhelpCmd: help\n", "q", `command cannot share same label or alias as exit command "q"`},
+		{"command is help alias", "exitCmd: exit\nhelpCmd: [help, \"?\"]\n", "?", `command cannot share same label or alias as help command "?"`},
+		{"repeated alias", "exitCmd: [quit, q]\nhelpCmd: [help, q]\n", "get", `multiple occurrences of the exit or help command "q"`},
+		{"empty alias", "exitCmd: [quit, \"\"]\nhelpCmd: help\n", "get", `invalid exit or help command ""`},
+		{"no exit command", "exitCmd: []\nhelpCmd: help\n", "get", `missing/empty exit command "exitCmd"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(test.header + "commands:\n  - label: \"" + test.label + "\"\n    arguments:\n      - label: \"\"\n"))
+			if err == nil || err.Error() != test.want {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestAliasesExport(t *testing.T) {
+	for _, config := range []string{aliasesConfig, testHeader + strings.SplitN(aliasesConfig, "exitFunc: Exit\n", 2)[1]} {
+		c, err := LoadConfigBytes([]byte(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exported, err := c.Export()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reloaded, err := LoadConfigBytes(exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := reloaded.Export()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(exported) || !reflect.DeepEqual(reloaded.ExitCmd, c.ExitCmd) || !reflect.DeepEqual(reloaded.HelpCmd, c.HelpCmd) {
+			t.Errorf("exporting is not stable, got\n%s\nthen\n%s", exported, again)
+		}
+	}
+}
+
+func TestAliasesJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"exitCmd": ["quit", "q"], "helpCmd": "help", "initFunc": "Init", "exitFunc": "Exit", "commands": [{"label": "get", "arguments": [{"label": ""}]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfigJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config.ExitCmd, Names{"quit", "q"}) || !reflect.DeepEqual(config.HelpCmd, Names{"help"}) {
+		t.Errorf("LoadConfigJSON() exit commands %q, help commands %q", config.ExitCmd, config.HelpCmd)
+	}
+}
+
+const funcsConfig = `
+promptFunc: Prompt
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+`
+
+func TestWithFuncs(t *testing.T) {
+	var called []string
+	call := func(name string) func(Flags) []byte {
+		return func(Flags) []byte {
+			called = append(called, name)
+			return []byte(name + "\n")
+		}
+	}
+	config := loadTestConfig(t, funcsConfig)
+	err := config.WithFuncs(map[string]func(Flags) []byte{"Init": call("init"), "Exit": call("exit"), "GetAll": call("all"), "Prompt": output("$ ")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(strings.Builder)
+	NewWithIO(config, strings.NewReader("get all\n"), out).Run()
+	if want := []string{"init", "all", "exit"}; !reflect.DeepEqual(called, want) {
+		t.Errorf("called %q, want %q", called, want)
+	}
+	if want := "init\n$ all\n$ exit\n"; out.String() != want {
+		t.Errorf("Run() wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestWithFuncsMissing(t *testing.T) {
+	funcs := map[string]func(Flags) []byte{"Init": output(""), "Exit": output(""), "GetAll": output(""), "Prompt": output("")}
+	for _, name := range []string{"Init", "Exit", "GetAll", "Prompt"} {
+		t.Run(name, func(t *testing.T) {
+			missing := make(map[string]func(Flags) []byte)
+			for fn, action := range funcs {
+				if fn != name {
+					missing[fn] = action
+				}
+			}
+			err := loadTestConfig(t, funcsConfig).WithFuncs(missing)
+			if want := "unable to find function \"" + name + "\""; err == nil || err.Error() != want {
+				t.Errorf("WithFuncs() = %v, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestWithFuncsReload(t *testing.T) {
+	config := &Config{
+		ExitCmd:  Names{"exit"},
+		HelpCmd:  Names{"help"},
+		InitFunc: "Init",
+		ExitFunc: "Exit",
+		Commands: []Command{{Label: "get", Arguments: []Argument{{Label: "all", ExecFunc: "GetAll"}}}},
+	}
+	if err := config.WithFuncs(map[string]func(Flags) []byte{"Init": output(""), "Exit": output("bye\n"), "GetAll": output("all\n")}); err != nil {
+		t.Fatal(err)
+	}
+	app := NewWithIO(loadTestConfig(t, funcsConfig), strings.NewReader(""), io.Discard)
+	if err := app.Reload(config); err != nil {
+		t.Fatal(err)
+	}
+	app.swapConfig()
+	for input, want := range map[string]string{"get all": "all\n", "exit": "bye\n"} {
+		if got := string(app.Execute(input)); got != want {
+			t.Errorf("Execute(%q) = %q after reload, want %q", input, got, want)
+		}
+	}
+}
+
+const exportConfig = `
+prompt: "> "
+version: 1.2.0
+versionCmd: version
+longValueSeparators: ["=", ":"]
+commands:
+  - label: get
+    aliases: [g]
+    help: Get things
+    arguments:
+      - label: all
+        help: Get all the things
+        execFunc: GetAll
+        options:
+          - label: number
+            short: -n
+            long: --number
+            help: How many
+            required: true
+            variable:
+              label: number
+              minLength: 1
+              default: "5"
+          - label: format
+            long: --format
+            variable:
+              label: format
+              choices: [json, yaml]
+      - label: remote
+        arguments:
+          - label: origin
+            execFunc: Origin
+`
+
+func TestExportDefaults(t *testing.T) {
+	config := loadTestConfig(t, getConfig)
+	exported, err := config.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, setting := range []string{"longValueSeparators", "delimiters", "separator"} {
+		if strings.Contains(string(exported), setting) {
+			t.Errorf("expected the default %s not to be exported\n%s", setting, exported)
+		}
+	}
+	if config.LongValueSeparators != nil {
+		t.Errorf("LongValueSeparators = %q after loading, want the config unchanged", config.LongValueSeparators)
+	}
+	reloaded, err := LoadConfigBytes(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := reloaded.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(exported) {
+		t.Errorf("exporting the reloaded config differs, got\n%s\nwant\n%s", again, exported)
+	}
+}
+
+func TestExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testHeader+exportConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := config.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadConfigBytes(exported)
+	if err != nil {
+		t.Fatalf("unable to load the exported config: %v\n%s", err, exported)
+	}
+	again, err := reloaded.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(exported) {
+		t.Errorf("exporting the reloaded config differs, got\n%s\nwant\n%s", again, exported)
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"prompt", reloaded.Prompt, config.Prompt},
+		{"version", reloaded.Version, config.Version},
+		{"separators", reloaded.LongValueSeparators, config.LongValueSeparators},
+		{"aliases", reloaded.Commands[0].Aliases, config.Commands[0].Aliases},
+		{"options", reloaded.Commands[0].Arguments[0].Options, config.Commands[0].Arguments[0].Options},
+		{"child arguments", reloaded.Commands[0].Arguments[1].Arguments[0].ExecFunc, "Origin"},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s = %v after exporting, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestLoadConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	commands := filepath.Join(dir, "commands")
+	if err := os.Mkdir(commands, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "base.yaml"):                 testHeader + "commands:\n  - label: get\n    arguments:\n      - label: \"\"\n",
+		filepath.Join(commands, "b.yaml"):               "commands:\n  - label: set\n    arguments:\n      - label: \"\"\n",
+		filepath.Join(commands, "a.yaml"):               "commands:\n  - label: del\n    arguments:\n      - label: \"\"\n  - label: add\n    arguments:\n      - label: \"\"\n",
+		filepath.Join(commands, "ab.yml"):               "commands:\n  - label: put\n    arguments:\n      - label: \"\"\n",
+		filepath.Join(commands, "c.json"):               `{"commands": [{"label": "new", "arguments": [{"label": ""}]}]}`,
+		filepath.Join(commands, "ignored.txt"):          "commands:\n  - label: ignored\n",
+		filepath.Join(dir, "duplicate.yaml"):            "commands:\n  - label: get\n    arguments:\n      - label: \"\"\n",
+		filepath.Join(dir, "invalid", "yaml", "x.yaml"): "commands: {",
+		filepath.Join(dir, "invalid", "yml", "y.yml"):   "commands: {",
+		filepath.Join(dir, "invalid", "json", "z.json"): `{"commands": [`,
+		filepath.Join(dir, "fragment", "x.yaml"):        "prompt: \"$ \"\ncommands:\n  - label: x\n    arguments:\n      - label: \"\"\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config, err := LoadConfigDir(filepath.Join(dir, "base.yaml"), commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"get", "del", "add", "put", "set", "new"}; !reflect.DeepEqual(config.CommandLabels(), want) {
+		t.Errorf("CommandLabels() = %q, want %q", config.CommandLabels(), want)
+	}
+
+	// A duplicate command across the files fails validation
+	duplicates := filepath.Join(dir, "duplicates")
+	if err := os.Mkdir(duplicates, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "duplicate.yaml"), filepath.Join(duplicates, "duplicate.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigDir(filepath.Join(dir, "base.yaml"), duplicates); err == nil || !strings.Contains(err.Error(), `multiple occurrences of the command label or alias "get"`) {
+		t.Errorf("expected a duplicate command error, got %v", err)
+	}
+	for _, name := range []string{"x.yaml", "y.yml", "z.json"} {
+		if _, err := LoadConfigDir(filepath.Join(dir, "base.yaml"), filepath.Join(dir, "invalid", strings.TrimPrefix(filepath.Ext(name), "."))); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected an error naming the invalid file %q, got %v", name, err)
+		}
+	}
+	if config, err := LoadConfigDir(filepath.Join(dir, "base.yaml"), filepath.Join(dir, "fragment")); err != nil || config.Prompt != "" {
+		t.Errorf("expected only the commands of a fragment to be loaded, got prompt %q, %v", config.Prompt, err)
+	}
+}
+
+// testProgram is a program with the methods used by the lazy exec tests.
+type testProgram struct{}
+
+func (testProgram) Init(Flags) []byte { return nil }
+func (testProgram) Exit(Flags) []byte { return nil }
+func (testProgram) Get(Flags) []byte  { return []byte("got\n") }
+
+func TestLazyExec(t *testing.T) {
+	tests := []struct {
+		name      string
+		lazy      bool
+		execFunc  string
+		usingErr  bool
+		verifyErr bool
+		want      string
+	}{
+		{"eager", false, "Get", false, false, "got\n"},
+		{"eager missing", false, "Missing", true, true, ""},
+		{"lazy", true, "Get", false, false, "got\n"},
+		{"lazy missing", true, "Missing", false, true, "unable to find method \"Missing\" for type \"cli.testProgram\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := loadTestConfig(t, "commands:\n  - label: get\n    arguments:\n      - label: all\n        execFunc: "+test.execFunc+"\n")
+			config.LazyExec = test.lazy
+			app, err := NewWithIO(config, strings.NewReader(""), io.Discard).Using(testProgram{})
+			if (err != nil) != test.usingErr {
+				t.Fatalf("Using() = %v, want error %t", err, test.usingErr)
+			}
+			if err := app.Verify(); (err != nil) != test.verifyErr {
+				t.Errorf("Verify() = %v, want error %t", err, test.verifyErr)
+			}
+			if test.usingErr {
+				return
+			}
+			for i := 0; i < 2; i++ {
+				if got := string(app.Execute("get all")); got != test.want {
+					t.Errorf("Execute(\"get all\") = %q, want %q", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUsing compares the startup of a config with many commands,
+// with the methods looked up eagerly and lazily.
+func BenchmarkUsing(b *testing.B) {
+	var yaml strings.Builder
+	yaml.WriteString("commands:\n")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&yaml, "  - label: cmd%d\n    arguments:\n      - label: get\n        execFunc: Get\n      - label: all\n        execFunc: Get\n", i)
+	}
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy=%t", lazy), func(b *testing.B) {
+			config := loadTestConfig(b, yaml.String())
+			config.LazyExec = lazy
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := config.withProgram(testProgram{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		json    string
+		wantErr bool
+	}{
+		{
+			"commands",
+			testHeader + inheritConfig,
+			`{"exitCmd": "exit", "helpCmd": "help", "initFunc": "Init", "exitFunc": "Exit", "commands": [{"label": "remote",
+				"options": [{"label": "verbose", "short": "-v", "long": "--verbose"}],
+				"arguments": [
+					{"label": "list", "execFunc": "List", "options": [{"label": "all", "short": "-a"}]},
+					{"label": "show", "execFunc": "Show", "options": [{"label": "verbose", "short": "-V"}]},
+					{"label": "add", "arguments": [{"label": "origin", "execFunc": "Origin"}]}]}]}`,
+			false,
+		},
+		{
+			"variables",
+			testHeader + getConfig + "          - label: number\n            short: -n\n            variable:\n              label: number\n              default: \"1\"\n",
+			`{"exitCmd": "exit", "helpCmd": "help", "initFunc": "Init", "exitFunc": "Exit", "commands": [{"label": "get",
+				"arguments": [{"label": "all", "execFunc": "GetAll", "options": [
+					{"label": "quiet", "short": "-q"},
+					{"label": "number", "short": "-n", "variable": {"label": "number", "default": "1"}}]}]}]}`,
+			false,
+		},
+		{
+			"invalid",
+			testHeader + "commands:\n  - label: get\n",
+			`{"exitCmd": "exit", "helpCmd": "help", "initFunc": "Init", "exitFunc": "Exit", "commands": [{"label": "get"}]}`,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			yamlPath, jsonPath := filepath.Join(dir, "config.yaml"), filepath.Join(dir, "config.json")
+			if err := os.WriteFile(yamlPath, []byte(test.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(jsonPath, []byte(test.json), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			fromYAML, yamlErr := LoadConfig(yamlPath)
+			fromJSON, jsonErr := LoadConfigJSON(jsonPath)
+			if test.wantErr {
+				if yamlErr == nil || jsonErr == nil || yamlErr.Error() != jsonErr.Error() {
+					t.Errorf("LoadConfig() = %v, LoadConfigJSON() = %v, want the same error", yamlErr, jsonErr)
+				}
+				return
+			}
+			if yamlErr != nil || jsonErr != nil {
+				t.Fatalf("LoadConfig() = %v, LoadConfigJSON() = %v", yamlErr, jsonErr)
+			}
+			want, err := yaml.Marshal(fromYAML.Commands)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := yaml.Marshal(fromJSON.Commands)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("LoadConfigJSON() commands:\n%s\nwant the commands from LoadConfig():\n%s", got, want)
+			}
+		})
+	}
+}
+
+// errReader is a reader which always fails with the error
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestLoadConfigInMemory(t *testing.T) {
+	load := map[string]func(string) (*Config, error){
+		"LoadConfigBytes":      func(s string) (*Config, error) { return LoadConfigBytes([]byte(s)) },
+		"LoadConfigFromReader": func(s string) (*Config, error) { return LoadConfigFromReader(strings.NewReader(s)) },
+	}
+	tests := []struct {
+		name   string
+		yaml   string
+		labels []string
+		err    string
+	}{
+		{"commands", testHeader + inheritConfig, []string{"remote"}, ""},
+		{"empty", "", nil, `missing/empty exit command "exitCmd"`},
+		{"invalid yaml", "commands: {", nil, "yaml: line 1: did not find expected node content"},
+		{"invalid config", testHeader + "commands:\n  - label: get\n", nil, `command "get" requires at least one argument`},
+	}
+	for name, fn := range load {
+		for _, test := range tests {
+			t.Run(name+" "+test.name, func(t *testing.T) {
+				config, err := fn(test.yaml)
+				if test.err != "" {
+					if err == nil || err.Error() != test.err {
+						t.Errorf("%s() = %v, want %q", name, err, test.err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(config.CommandLabels(), test.labels) {
+					t.Errorf("%s() commands %q, want %q", name, config.CommandLabels(), test.labels)
+				}
+			})
+		}
+	}
+	if _, err := LoadConfigFromReader(errReader{io.ErrUnexpectedEOF}); err != io.ErrUnexpectedEOF {
+		t.Errorf("LoadConfigFromReader() = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+const caseConfig = `
+caseInsensitive: true
+commands:
+  - label: List
+    arguments:
+      - label: Files
+        execFunc: Files
+      - label: all users
+        execFunc: Users
+`
+
+func TestCaseInsensitive(t *testing.T) {
+	funcs := map[string]func(Flags) []byte{"Files": output("files\n"), "Users": output("users\n")}
+	tests := []struct {
+		input       string
+		insensitive bool
+		want        string
+	}{
+		{"List Files", true, "files\n"},
+		{"list files", true, "files\n"},
+		{"LIST FILES", true, "files\n"},
+		{"lIsT aLL UsErs", true, "users\n"},
+		{"List Files", false, "files\n"},
+		{"list Files", false, "unable to find command \"list\", did you mean \"List\"?\n"},
+		{"List files", false, "invalid use of the \"List\" command, no valid argument provided\n"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s insensitive=%t", test.input, test.insensitive), func(t *testing.T) {
+			config := caseConfig
+			if !test.insensitive {
+				config = strings.Replace(config, "caseInsensitive: true", "", 1)
+			}
+			app, _ := newTestApp(t, config, funcs, "")
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+
+	// The original casing is preserved in the help
+	app, _ := newTestApp(t, caseConfig, funcs, "")
+	if help := string(app.Execute("list help")); !strings.Contains(help, "Usage: List") || !strings.Contains(help, "List Files") {
+		t.Errorf("Execute(\"list help\") = %q, want the original casing", help)
+	}
+}
+
+func TestCaseInsensitiveDuplicates(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"commands", "commands:\n  - label: list\n    arguments:\n      - label: \"\"\n  - label: LIST\n    arguments:\n      - label: \"\"\n", `multiple occurrences of the command label or alias "LIST"`},
+		{"alias", "commands:\n  - label: list\n    aliases: [ls]\n    arguments:\n      - label: \"\"\n  - label: Ls\n    arguments:\n      - label: \"\"\n", `multiple occurrences of the command label or alias "Ls"`},
+		{"arguments", "commands:\n  - label: list\n    arguments:\n      - label: files\n      - label: Files\n", `command "list", multiple occurrences of the argument label "Files" ignoring case`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := LoadConfigBytes([]byte(testHeader + test.config)); err != nil {
+				t.Fatalf("expected the labels to be valid when case sensitive, got %v", err)
+			}
+			_, err := LoadConfigBytes([]byte(testHeader + "caseInsensitive: true\n" + test.config))
+			if err == nil || err.Error() != test.want {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestReservedNames(t *testing.T) {
+	header := "exitCmd: [exit, --quit]\nhelpCmd: [help, --help]\ninitFunc: Init\nexitFunc: Exit\n"
+	option := "commands:\n  - label: get\n    arguments:\n      - label: all\n        options:\n          - label: %s\n            %s\n"
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"option long as the help command", fmt.Sprintf(option, "help", "long: --help"), `command "get", argument "all", option "help" cannot share same name as help command "--help"`},
+		{"option long as the exit command", fmt.Sprintf(option, "quit", "long: --quit"), `command "get", argument "all", option "quit" cannot share same name as exit command "--quit"`},
+		{"argument label as the exit command", "commands:\n  - label: get\n    arguments:\n      - label: exit\n", `command "get", argument cannot share same label as exit command "exit"`},
+		{"argument label as the help command", "commands:\n  - label: get\n    arguments:\n      - label: help\n", `command "get", argument label "help" cannot end with the help command "help"`},
+		{"argument label ending with the help command", "commands:\n  - label: get\n    arguments:\n      - label: more help\n", `command "get", argument label "more help" cannot end with the help command "help"`},
+		{"nested argument label as the exit command", "commands:\n  - label: get\n    arguments:\n      - label: all\n        arguments:\n          - label: exit\n", `command "get", argument cannot share same label as exit command "exit"`},
+		{"command label as the exit command", "commands:\n  - label: exit\n    arguments:\n      - label: all\n", `command cannot share same label or alias as exit command "exit"`},
+		{"option long containing the help command", fmt.Sprintf(option, "helpful", "long: --helpful"), ""},
+		{"argument label containing the exit command", "commands:\n  - label: get\n    arguments:\n      - label: exits\n", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(header + test.config))
+			if (err == nil && test.want != "") || (err != nil && err.Error() != test.want) {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestVersionCmdInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{"missing version", "versionCmd: version\n", `missing/empty version "version" for the version command "version"`},
+		{"exit command", "version: 1.0.0\nversionCmd: exit\n", `version command "exit" cannot share same label as the exit, help or reset command`},
+		{"help command", "version: 1.0.0\nversionCmd: help\n", `version command "help" cannot share same label as the exit, help or reset command`},
+		{"reset command", "version: 1.0.0\nversionCmd: reset\nresetCmd: reset\n", `version command "reset" cannot share same label as the exit, help or reset command`},
+		{"command label", "version: 1.0.0\nversionCmd: get\n", `command cannot share same label or alias as version command "get"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(testHeader + test.version + getConfig))
+			if err == nil || err.Error() != test.want {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+// signatureProgram is a program with methods of various signatures
+type signatureProgram struct{}
+
+func (signatureProgram) Valid(Flags) []byte                 { return []byte("valid\n") }
+func (signatureProgram) Stream(_ Flags, w io.Writer) []byte { return []byte("stream\n") }
+func (signatureProgram) Failing(Flags) ([]byte, error)      { return []byte("failing\n"), nil }
+func (signatureProgram) Text(Flags) string                  { return "text" }
+func (signatureProgram) NoArgs() []byte                     { return nil }
+func (signatureProgram) Extra(Flags, string) []byte         { return nil }
+func (signatureProgram) unexported(Flags) []byte            { return nil }
+func (*signatureProgram) Pointer(Flags) []byte              { return []byte("pointer\n") }
+
+func TestGetExecutable(t *testing.T) {
+	const want = `, must be func(Flags) []byte, func(Flags, io.Writer) []byte, func(context.Context, Flags) []byte, func(Flags) ([]byte, error) or func(Flags, *Session) []byte`
+	tests := []struct {
+		name     string
+		program  interface{}
+		funcName string
+		output   string
+		err      string
+	}{
+		{"valid", signatureProgram{}, "Valid", "valid\n", ""},
+		{"streaming", signatureProgram{}, "Stream", "stream\n", ""},
+		{"returning an error", signatureProgram{}, "Failing", "failing\n", ""},
+		{"pointer receiver", &signatureProgram{}, "Pointer", "pointer\n", ""},
+		{"missing", signatureProgram{}, "Missing", "", `unable to find method "Missing" for type "cli.signatureProgram"`},
+		{"unexported", signatureProgram{}, "unexported", "", `unable to find method "unexported" for type "cli.signatureProgram"`},
+		{"pointer receiver on value", signatureProgram{}, "Pointer", "", `unable to find method "Pointer" for type "cli.signatureProgram"`},
+		{"nil program", nil, "Valid", "", `unable to find method "Valid" for type "<nil>"`},
+		{"returns a string", signatureProgram{}, "Text", "", `method "Text" for type "cli.signatureProgram" has invalid type "func(cli.Flags) string"` + want},
+		{"no parameters", signatureProgram{}, "NoArgs", "", `method "NoArgs" for type "cli.signatureProgram" has invalid type "func() []uint8"` + want},
+		{"extra parameter", signatureProgram{}, "Extra", "", `method "Extra" for type "cli.signatureProgram" has invalid type "func(cli.Flags, string) []uint8"` + want},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			action, err := getExecutable(test.program, test.funcName)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("getExecutable(%q) = %v, want %q", test.funcName, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(action(Flags{})); got != test.output {
+				t.Errorf("getExecutable(%q) output = %q, want %q", test.funcName, got, test.output)
+			}
+		})
+	}
+
+	// The errors are returned when the methods are applied to the config
+	program := struct {
+		testProgram
+		signatureProgram
+	}{}
+	config := loadTestConfig(t, "commands:\n  - label: get\n    arguments:\n      - label: all\n        execFunc: Text\n")
+	_, err := NewWithIO(config, strings.NewReader(""), io.Discard).Using(program)
+	if want := `method "Text" for type "struct { cli.testProgram; cli.signatureProgram }" has invalid type "func(cli.Flags) string"` + want; err == nil || err.Error() != want {
+		t.Errorf("Using() = %v, want %q", err, want)
+	}
+}
+
+func TestRequireAllExecFuncs(t *testing.T) {
+	config := `
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: Get
+      - label: one
+  - label: remote
+    arguments:
+      - label: add
+        arguments:
+          - label: origin
+`
+	tests := []struct {
+		name    string
+		require bool
+		err     string
+	}{
+		{"required", true, `no execFunc configured for the arguments "get one", "remote add origin"`},
+		{"not required", false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yaml := config
+			if test.require {
+				yaml = "requireAllExecFuncs: true\n" + yaml
+			}
+
+			// The arguments are checked when the methods are applied from a program
+			app, err := NewWithIO(loadTestConfig(t, yaml), strings.NewReader(""), io.Discard).Using(testProgram{})
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("Using() = %v, want %q", err, test.err)
+			}
+			if test.err == "" {
+				if got, want := string(app.Execute("get one")), "\"\" is not configured\n"; got != want {
+					t.Errorf("Execute(\"get one\") = %q, want %q", got, want)
+				}
+			}
+
+			// or from the functions
+			err = loadTestConfig(t, yaml).WithFuncs(map[string]func(Flags) []byte{"Init": output(""), "Exit": output(""), "Get": output("")})
+			if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+				t.Errorf("WithFuncs() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+// maxSuggestionDistance is the maximum edit distance for a suggestion
+const maxSuggestionDistance = 2
+
+// levenshtein returns the edit distance between the strings, namely the
+// minimum number of single character insertions, deletions or
+// substitutions required to change one string into the other.
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
+
+// closest returns the candidate closest to the word by edit distance, if
+// the distance is small enough for the candidate to be a likely typo of
+// the word, namely at most maxSuggestionDistance and at most a third of the
+// length of the word, though a single edit is always allowed, e.g. "gt" for
+// "get". The first of any equally close candidates is returned.
+func (config *Config) closest(word string, candidates []string) (closest string, ok bool) {
+	best := maxSuggestionDistance + 1
+	allowed := len([]rune(word)) / 3
+	if allowed < 1 {
+		allowed = 1
+	}
+	for _, candidate := range candidates {
+		distance := levenshtein(config.foldCase(word), config.foldCase(candidate))
+		if distance < best && distance <= allowed {
+			closest, best, ok = candidate, distance, true
+		}
+	}
+	return closest, ok
+}
+
+// minInt returns the smallest of the integers
+func minInt(values ...int) int {
+	smallest := values[0]
+	for _, value := range values[1:] {
+		if value < smallest {
+			smallest = value
+		}
+	}
+	return smallest
+}
@@ -0,0 +1,91 @@
+package cli
+
+import "testing"
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"get", "get", 0},
+		{"gt", "get", 1},
+		{"gte", "get", 2},
+		{"", "get", 3},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"get", "set", "delete", "status"}
+	tests := []struct {
+		word            string
+		caseInsensitive bool
+		want            string
+		ok              bool
+	}{
+		{"gt", false, "get", true},
+		{"gett", false, "get", true},
+		{"delet", false, "delete", true},
+		{"dleete", false, "delete", true},
+		{"stat", false, "", false},
+		{"statsu", false, "status", true},
+		{"xyz", false, "", false},
+		{"GET", false, "", false},
+		{"GT", true, "get", true},
+		{"et", false, "get", true},
+	}
+	for _, test := range tests {
+		t.Run(test.word, func(t *testing.T) {
+			config := &Config{CaseInsensitive: test.caseInsensitive}
+			got, ok := config.closest(test.word, candidates)
+			if got != test.want || ok != test.ok {
+				t.Errorf("closest(%q) = %q, %t, want %q, %t", test.word, got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestUnknownCommandSuggestion(t *testing.T) {
+	config := `
+commands:
+  - label: list
+    aliases: [ls]
+    arguments:
+      - label: ""
+        execFunc: List
+  - label: remove
+    arguments:
+      - label: ""
+        execFunc: Remove
+`
+	app, _ := newTestApp(t, config, map[string]func(Flags) []byte{"List": output(""), "Remove": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"lst", "unable to find command \"lst\", did you mean \"list\"?\n"},
+		{"listt", "unable to find command \"listt\", did you mean \"list\"?\n"},
+		{"remvoe", "unable to find command \"remvoe\", did you mean \"remove\"?\n"},
+		{"lz", "unable to find command \"lz\", did you mean \"ls\"?\n"},
+		{"exitt", "unable to find command \"exitt\", did you mean \"exit\"?\n"},
+		{"hlp", "unable to find command \"hlp\", did you mean \"help\"?\n"},
+		{"lsit", "unable to find command \"lsit\"\n"},
+		{"deploy", "unable to find command \"deploy\"\n"},
+		{"xyz", "unable to find command \"xyz\"\n"},
+		{"removeeverything", "unable to find command \"removeeverything\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,121 @@
 package cli
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
 // Flags stores data for the options and variables for a command.
+// Flags is read-only once it has been parsed from the input, none of
+// its methods mutate the underlying mapping, so it is safe to read the
+// same Flags from multiple goroutines, e.g. in middleware or background
+// jobs. Any method added which modifies Flags must return a copy.
 type Flags struct {
-	mapping map[string]flagMetadata
+	mapping     map[string]flagMetadata
+	state       *State
+	rawInput    string
+	positional  string
+	args        []string
+	passThrough []string
+	stream      io.Writer
+	ctx         context.Context
+	fail        func(error)
+	session     *Session
+	format      helpFormat
+
+	// the separator between the name and the value of a long option
+	// in the input, used to reconstruct the command line
+	longSeparator string
 }
 
+// Sources describing where the value of a flag came from.
+const (
+	sourceInput   = "input"
+	sourceDefault = "default"
+	sourceSticky  = "sticky"
+	sourceEnv     = "env"
+)
+
 // flagsMetadata stores data for a single options and variable if applicable.
 type flagMetadata struct {
+	option   Option
 	isset    bool
 	hasVar   bool
 	variable string
+	object   []byte
+
+	// The values of every occurrence of a repeatable option
+	variables []string
+
+	// The number of occurrences of the option in the input
+	count int
+
+	// Whether the option was negated in the input, e.g. (--no-color),
+	// which sets the option as explicitly false
+	negated bool
+
+	// Where the variable came from and the raw text
+	// from the input which set this flag.
+	source string
+	raw    string
+}
+
+// newFlagMetadata creates the metadata for an option which has not been set.
+// If the option has a variable, the metadata carries its default value.
+func newFlagMetadata(option Option) flagMetadata {
+	meta := flagMetadata{
+		option:   option,
+		isset:    false,
+		hasVar:   false,
+		variable: "",
+	}
+	if option.Variable != nil {
+		meta.hasVar = true
+		meta.variable = option.Variable.Default
+		if variable, err := convertUnit(option.Variable.Unit, meta.variable); err == nil {
+			meta.variable = variable
+		}
+	}
+	return meta
+}
+
+// NewFlags creates Flags with the given options, e.g. to test an executable
+// without parsing an input. Each label in values is an option with the given
+// variable, which is set unless set is false for the label. Each other label
+// in set is an option without a variable, which is set if set is true for
+// the label.
+func NewFlags(values map[string]string, set map[string]bool) Flags {
+	mapping := make(map[string]flagMetadata, len(values)+len(set))
+	for label, isset := range set {
+		mapping[label] = flagMetadata{option: Option{Label: label}, isset: isset}
+	}
+	for label, variable := range values {
+		isset, ok := set[label]
+		mapping[label] = flagMetadata{
+			option:   Option{Label: label, Variable: &Variable{Label: label}},
+			isset:    isset || !ok,
+			hasVar:   true,
+			variable: variable,
+		}
+	}
+
+	// Each option which is set is given once in the input
+	for label, meta := range mapping {
+		if meta.isset {
+			meta.count, meta.source = 1, sourceInput
+			mapping[label] = meta
+		}
+	}
+	return Flags{mapping: mapping}
 }
 
 // Exists returns whether the given label exists in the Flags.
@@ -37,3 +143,368 @@ func (flags Flags) GetVar(label string) (variable string, exists bool) {
 	}
 	return meta.variable, true
 }
+
+// Count returns the number of times the option with the given label was
+// given in the input, including within combined short flags, e.g. 3 for
+// (-vvv) or 2 for (--verbose --verbose). If the option has not been set
+// in the input or doesn't exist in Flags, 0 will be returned instead.
+func (flags Flags) Count(label string) int {
+	return flags.mapping[label].count
+}
+
+// GetVars returns the variables set for every occurrence of the option with
+// the given label, in the order they were given. For an option which is not
+// repeatable, only the variable of the last occurrence is returned. If the
+// option has not been set, doesn't have a variable or doesn't exist in
+// Flags, <nil> will be returned instead.
+func (flags Flags) GetVars(label string) []string {
+	meta, ok := flags.mapping[label]
+	if !ok || !meta.isset || !meta.hasVar {
+		return nil
+	}
+	return meta.values()
+}
+
+// GetKeyValue returns the key and value of the key value pair set for the
+// option with the given label, e.g. ("NAME", "VALUE") for (--env NAME=VALUE).
+// If the option has not been set, doesn't have a variable, doesn't exist in
+// Flags or its variable is not a key value pair, ("", "", false) will be
+// returned instead.
+func (flags Flags) GetKeyValue(label string) (key, value string, ok bool) {
+	variable, ok := flags.GetVar(label)
+	if !ok {
+		return "", "", false
+	}
+	return splitKeyValue(variable)
+}
+
+// GetKeyValues returns a map of the key value pairs set for every occurrence
+// of the option with the given label. If a key is given more than once, the
+// value of the last occurrence is used. If the option has not been set,
+// doesn't have a variable or doesn't exist in Flags, <nil> will be returned
+// instead.
+func (flags Flags) GetKeyValues(label string) map[string]string {
+	values := flags.GetVars(label)
+	if values == nil {
+		return nil
+	}
+	pairs := make(map[string]string, len(values))
+	for _, variable := range values {
+		if key, value, ok := splitKeyValue(variable); ok {
+			pairs[key] = value
+		}
+	}
+	return pairs
+}
+
+// values returns the values of every occurrence of the flag, or just
+// the variable if the values of the occurrences were not collected.
+func (meta flagMetadata) values() []string {
+	if len(meta.variables) == 0 {
+		return []string{meta.variable}
+	}
+	return append([]string{}, meta.variables...)
+}
+
+// GetInt returns the variable set for the option with the given label as an int.
+// An error is returned if the variable is missing or is not a valid integer.
+func (flags Flags) GetInt(label string) (int, error) {
+	variable, err := flags.getRequiredVar(label)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.Atoi(variable)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer \"%s\" for option \"%s\"", flags.mapping[label].option.mask(variable), label)
+	}
+	return value, nil
+}
+
+// GetFloat returns the variable set for the option with the given label as a float64.
+// An error is returned if the variable is missing or is not a valid number.
+func (flags Flags) GetFloat(label string) (float64, error) {
+	variable, err := flags.getRequiredVar(label)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseFloat(variable, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number \"%s\" for option \"%s\"", flags.mapping[label].option.mask(variable), label)
+	}
+	return value, nil
+}
+
+// GetBool returns the variable set for the option with the given label as a bool.
+// The values true/false, 1/0 and yes/no are accepted, ignoring case.
+// An error is returned if the variable is missing or is not a valid boolean.
+//
+// For an option without a variable, true is returned if the option has been
+// set, or false if the option has been negated, e.g. (--no-color) for the
+// option (--color). An error is returned if the option has been omitted, to
+// distinguish an option which is explicitly false from one which is unset.
+func (flags Flags) GetBool(label string) (bool, error) {
+	if meta, ok := flags.mapping[label]; ok && meta.option.Variable == nil {
+		switch {
+		case meta.isset:
+			return true, nil
+		case meta.negated:
+			return false, nil
+		}
+		return false, fmt.Errorf("option \"%s\" has not been set", label)
+	}
+	variable, err := flags.getRequiredVar(label)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(variable) {
+	case "true", "1", "yes":
+		return true, nil
+	case "false", "0", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean \"%s\" for option \"%s\"", flags.mapping[label].option.mask(variable), label)
+}
+
+// GetDuration returns the variable set for the option with the given label
+// as a time.Duration, for a variable with the "duration" unit.
+// An error is returned if the variable is missing or is not a valid duration.
+func (flags Flags) GetDuration(label string) (time.Duration, error) {
+	nanoseconds, err := flags.GetInt(label)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(nanoseconds), nil
+}
+
+// getRequiredVar returns the variable set for the option with the given label,
+// or an error if the option has not been set or doesn't have a variable.
+func (flags Flags) getRequiredVar(label string) (string, error) {
+	variable, ok := flags.GetVar(label)
+	if !ok {
+		return "", fmt.Errorf("missing variable for option \"%s\"", label)
+	}
+	return variable, nil
+}
+
+// GetVarOrDefault returns the variable set for the option with the given label.
+// If the option has not been set, the default value configured for its variable
+// is returned instead, converted like a value from the input if the variable has
+// a unit. If the option doesn't have a variable or doesn't exist in Flags, an
+// empty string is returned.
+func (flags Flags) GetVarOrDefault(label string) string {
+	if variable, ok := flags.GetVar(label); ok {
+		return variable
+	}
+	meta, ok := flags.mapping[label]
+	if !ok || meta.option.Variable == nil {
+		return ""
+	}
+	variable, err := convertUnit(meta.option.Variable.Unit, meta.option.Variable.Default)
+	if err != nil {
+		return meta.option.Variable.Default
+	}
+	return variable
+}
+
+// State returns the State of the CLI session the flags were parsed in.
+// If the flags were not parsed by an App, <nil> will be returned instead.
+func (flags Flags) State() *State {
+	return flags.state
+}
+
+// Stream returns the writer used to stream output to the CLI while the
+// executable is running, which is flushed on each complete line. If the
+// flags were not parsed by an App, a writer which discards all output
+// is returned instead.
+func (flags Flags) Stream() io.Writer {
+	if flags.stream == nil {
+		return io.Discard
+	}
+	return flags.stream
+}
+
+// Context returns the context of the command, which is cancelled when the
+// user interrupts the command with ctl-C. If the flags were not parsed by
+// an App, a context which is never cancelled is returned instead.
+func (flags Flags) Context() context.Context {
+	if flags.ctx == nil {
+		return context.Background()
+	}
+	return flags.ctx
+}
+
+// Session returns the Session of the CLI, which allows the executable to
+// prompt the user while it is running. If the flags were not parsed by an
+// App, a Session which never receives any answers is returned instead.
+func (flags Flags) Session() *Session {
+	if flags.session == nil {
+		return &Session{}
+	}
+	return flags.session
+}
+
+// Raw returns the input following the argument label, for an argument
+// configured as raw, or the unmatched input, for a default argument which
+// was invoked because no argument label was matched, or the whole input, for
+// the fallback function. For any other argument an empty string is returned.
+func (flags Flags) Raw() string {
+	return flags.rawInput
+}
+
+// Positional returns the text of the input which matched the argument label,
+// as it was typed, e.g. "Daily Tasks" for the label "daily tasks" when the
+// config is case insensitive. For an argument with an empty label, or a
+// default argument invoked because no argument label was matched, an empty
+// string is returned. For the fallback function, the whole input is returned.
+func (flags Flags) Positional() string {
+	return flags.positional
+}
+
+// Args returns the text following the argument label which isn't an option
+// or the variable of an option, split into tokens, for a free form argument.
+// For a default argument invoked because no argument label was matched, the
+// unmatched input is returned first. If there is no such text, <nil> is
+// returned instead.
+func (flags Flags) Args() []string {
+	return flags.args
+}
+
+// PassThrough returns the tokens following the terminator (--) in the input,
+// untouched by the option parsing, so that they can be forwarded to another
+// tool. If there was no terminator in the input, <nil> is returned instead.
+func (flags Flags) PassThrough() []string {
+	return flags.passThrough
+}
+
+// GetObject unmarshals the content of the file referenced by the variable
+// for the option with the given label into v. Files with a ".json" extension
+// are unmarshaled as JSON, any other file is unmarshaled as YAML.
+// An error is returned if the option has not been set, doesn't reference an
+// object or if the content cannot be unmarshaled into v.
+func (flags Flags) GetObject(label string, v interface{}) error {
+	meta, ok := flags.mapping[label]
+	if !ok || !meta.isset || meta.object == nil {
+		return fmt.Errorf("no object set for option \"%s\"", label)
+	}
+	if strings.EqualFold(filepath.Ext(meta.variable), ".json") {
+		return json.Unmarshal(meta.object, v)
+	}
+	return yaml.Unmarshal(meta.object, v)
+}
+
+// String returns a dump of the flags, listing each option with its set
+// state and, if it has a variable, its value or the values of every
+// occurrence for a repeatable option, sorted by label, e.g.
+// {all: set=true, name: set=false value=""}. The values of secret
+// options are masked.
+func (flags Flags) String() string {
+	parts := make([]string, 0, len(flags.mapping))
+	for _, label := range flags.labels() {
+		meta := flags.mapping[label]
+		part := fmt.Sprintf("%s: set=%t", label, meta.isset)
+		switch {
+		case meta.hasVar && meta.option.Secret:
+			part += fmt.Sprintf(" value=%q", secretMask)
+		case meta.hasVar && len(meta.variables) > 0:
+			part += fmt.Sprintf(" values=%q", meta.variables)
+		case meta.hasVar:
+			part += fmt.Sprintf(" value=%q", meta.variable)
+		}
+		parts = append(parts, part)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// debugString returns a dump of the fully resolved flags, listing each
+// option with its set state, value and source, sorted by label.
+func (flags Flags) debugString() string {
+	desc := "[debug] resolved flags:\n"
+	for _, label := range flags.labels() {
+		meta := flags.mapping[label]
+		if !meta.isset {
+			desc += fmt.Sprintf("[debug]\t%s: set=false\n", label)
+			continue
+		}
+		desc += fmt.Sprintf("[debug]\t%s: set=true", label)
+		if meta.hasVar {
+			desc += fmt.Sprintf(" value=%q", meta.option.mask(meta.variable))
+		}
+		desc += fmt.Sprintf(" source=%s", meta.source)
+
+		// The raw text of a secret option contains its values
+		if !meta.option.Secret {
+			desc += fmt.Sprintf(" raw=%q", meta.raw)
+		}
+		desc += "\n"
+	}
+	return desc
+}
+
+// CommandLine reconstructs a canonical invocation of the command and
+// argument from the flags which have been set. The long name of each
+// option is used where available, joined to any value by the first of the
+// long value separators, and any values containing spaces or quotes are
+// quoted.
+func (flags Flags) CommandLine(command, argument string) string {
+	separator := flags.longSeparator
+	if separator == "" {
+		separator = "="
+	}
+	parts := []string{command}
+	if argument != "" {
+		parts = append(parts, argument)
+	}
+	for _, label := range flags.labels() {
+		meta := flags.mapping[label]
+		if meta.negated {
+			parts = append(parts, negationPrefix+strings.TrimPrefix(meta.option.Long, "--"))
+		}
+		if !meta.isset {
+			continue
+		}
+		switch {
+		case meta.option.Long != "" && meta.hasVar:
+			for _, value := range meta.values() {
+				parts = append(parts, meta.option.Long+separator+quoteValue(value))
+			}
+		case meta.option.Long != "":
+			parts = append(parts, repeatFlag(meta.option.Long, meta.count)...)
+		case meta.hasVar:
+			for _, value := range meta.values() {
+				parts = append(parts, meta.option.Short, quoteValue(value))
+			}
+		default:
+			parts = append(parts, repeatFlag(meta.option.Short, meta.count)...)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// repeatFlag returns the name of an option without a variable repeated the
+// number of times it was given, and at least once, e.g. (-v -v -v) for (-vvv).
+func repeatFlag(name string, count int) []string {
+	parts := []string{name}
+	for i := 1; i < count; i++ {
+		parts = append(parts, name)
+	}
+	return parts
+}
+
+// labels returns the labels of the flags in sorted order
+func (flags Flags) labels() []string {
+	labels := make([]string, 0, len(flags.mapping))
+	for label := range flags.mapping {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// quoteValue wraps the value in double quotes if it is empty or contains
+// any whitespace, quotes or backslashes, escaping any quotes and backslashes.
+func quoteValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"'\\") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,909 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const keyValueConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: run
+    arguments:
+      - label: job
+        execFunc: Job
+        options:
+          - label: env
+            short: -e
+            long: --env
+            repeatable: true
+            keyValue: true
+            variable:
+              label: pair
+          - label: label
+            long: --label
+            keyValue: true
+            variable:
+              label: pair
+`
+
+func TestKeyValue(t *testing.T) {
+	var got Flags
+	app, _ := newTestApp(t, keyValueConfig, map[string]func(Flags) []byte{"Job": func(flags Flags) []byte {
+		got = flags
+		return nil
+	}}, "")
+	tests := []struct {
+		name  string
+		input string
+		label string
+		key   string
+		value string
+		pairs map[string]string
+	}{
+		{"single pair", "run job --label tier=web", "label", "tier", "web", map[string]string{"tier": "web"}},
+		{"long option", "run job --env=NAME=VALUE", "env", "NAME", "VALUE", map[string]string{"NAME": "VALUE"}},
+		{"next token", "run job --env NAME=VALUE", "env", "NAME", "VALUE", map[string]string{"NAME": "VALUE"}},
+		{"short option", "run job -e NAME=VALUE", "env", "NAME", "VALUE", map[string]string{"NAME": "VALUE"}},
+		{"empty value", "run job --env NAME=", "env", "NAME", "", map[string]string{"NAME": ""}},
+		{"repeated pairs", "run job -e A=1 --env B=2 --env=C=3", "env", "C", "3", map[string]string{"A": "1", "B": "2", "C": "3"}},
+		{"repeated key", "run job -e A=1 -e A=2", "env", "A", "2", map[string]string{"A": "2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got = Flags{}
+			if output := string(app.Execute(test.input)); output != "" {
+				t.Fatalf("Execute(%q) = %q", test.input, output)
+			}
+			key, value, ok := got.GetKeyValue(test.label)
+			if !ok || key != test.key || value != test.value {
+				t.Errorf("GetKeyValue(%s) = %q, %q, %t, want %q, %q", test.label, key, value, ok, test.key, test.value)
+			}
+			if pairs := got.GetKeyValues(test.label); !reflect.DeepEqual(pairs, test.pairs) {
+				t.Errorf("GetKeyValues(%s) = %v, want %v", test.label, pairs, test.pairs)
+			}
+		})
+	}
+	if key, value, ok := got.GetKeyValue("label"); ok {
+		t.Errorf("GetKeyValue(label) = %q, %q for an unset option", key, value)
+	}
+}
+
+func TestKeyValueInvalid(t *testing.T) {
+	app, _ := newTestApp(t, keyValueConfig, map[string]func(Flags) []byte{"Job": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"run job --env NAME", "invalid key value pair \"NAME\" for option \"env\", must be key=value"},
+		{"run job --env==VALUE", "invalid key value pair \"=VALUE\" for option \"env\", must be key=value"},
+		{"run job -e A=1 -e B", "invalid key value pair \"B\" for option \"env\", must be key=value"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := parseTest(app, test.input); got != test.want {
+				t.Errorf("Parse(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const objectConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: deploy
+    arguments:
+      - label: ""
+        execFunc: Deploy
+        options:
+          - label: config
+            long: --config
+            variable:
+              label: file
+              objectRef: true
+          - label: name
+            long: --name
+            variable:
+              label: name
+`
+
+func TestGetObject(t *testing.T) {
+	type deployment struct {
+		Name     string `json:"name" yaml:"name"`
+		Replicas int    `json:"replicas" yaml:"replicas"`
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"deploy.json":    `{"name": "web", "replicas": 3}`,
+		"deploy.yaml":    "name: web\nreplicas: 3\n",
+		"invalid.json":   `{"name": "web", "replicas": "three"}`,
+		"malformed.json": `{"name": `,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app, _ := newTestApp(t, objectConfig, map[string]func(Flags) []byte{"Deploy": output("")}, "")
+	tests := []struct {
+		name     string
+		input    string
+		label    string
+		want     deployment
+		parseErr bool
+		wantErr  bool
+	}{
+		{"json", "deploy --config=" + filepath.Join(dir, "deploy.json"), "config", deployment{"web", 3}, false, false},
+		{"yaml", "deploy --config=" + filepath.Join(dir, "deploy.yaml"), "config", deployment{"web", 3}, false, false},
+		{"wrong type", "deploy --config=" + filepath.Join(dir, "invalid.json"), "config", deployment{}, false, true},
+		{"malformed", "deploy --config=" + filepath.Join(dir, "malformed.json"), "config", deployment{}, false, true},
+		{"not set", "deploy", "config", deployment{}, false, true},
+		{"not an object", "deploy --name=web", "name", deployment{}, false, true},
+		{"missing file", "deploy --config=" + filepath.Join(dir, "missing.json"), "config", deployment{}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if (err != nil) != test.parseErr {
+				t.Fatalf("Parse(%q) = %v, want error %t", test.input, err, test.parseErr)
+			}
+			if err != nil {
+				return
+			}
+			var got deployment
+			err = flags.GetObject(test.label, &got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetObject(%s) = %v, want error %t", test.label, err, test.wantErr)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("GetObject(%s) = %+v, want %+v", test.label, got, test.want)
+			}
+		})
+	}
+}
+
+const debugConfig = `
+debugFlags: true
+longValueSeparators: ["="]
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: quiet
+            short: -q
+          - label: number
+            short: -n
+            long: --number
+            variable:
+              label: number
+              default: "10"
+`
+
+func TestDebugFlags(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"get all", "[debug] resolved flags:\n[debug]\tnumber: set=false\n[debug]\tquiet: set=false\n"},
+		{"get all -q -n 5", "[debug] resolved flags:\n[debug]\tnumber: set=true value=\"5\" source=input raw=\"-n 5\"\n[debug]\tquiet: set=true source=input raw=\"-q\"\n"},
+		{"get all --number", "[debug] resolved flags:\n[debug]\tnumber: set=true value=\"10\" source=default raw=\"--number\"\n[debug]\tquiet: set=false\n"},
+		{"get none", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			app, _ := newTestApp(t, debugConfig, map[string]func(Flags) []byte{"GetAll": output("")}, "")
+			stderr := new(bytes.Buffer)
+			app.WithErrWriter(stderr).Execute(test.input)
+			if got := stderr.String(); got != test.want {
+				t.Errorf("Execute(%q) wrote %q to the error stream, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFlagsConcurrentReads(t *testing.T) {
+	app, _ := newTestApp(t, keyValueConfig, map[string]func(Flags) []byte{"Job": output("")}, "")
+	_, _, flags, err := app.Parse("run job -e A=1 -e B=2 --label=tier=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := flags.String()
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got := flags.String(); got != want {
+					errs <- got
+					return
+				}
+				flags.GetVars("env")
+				flags.GetKeyValues("env")
+				flags.GetVarOrDefault("label")
+				flags.IsSet("label")
+				flags.Count("env")
+				flags.CommandLine("run", "job")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for got := range errs {
+		t.Errorf("String() = %q on another goroutine, want %q", got, want)
+	}
+}
+
+const appendConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: mycmd
+    arguments:
+      - label: arg
+        execFunc: Arg
+        options:
+          - label: append
+            long: --append
+            variable:
+              label: append
+`
+
+func TestLongOptionValue(t *testing.T) {
+	app, _ := newTestApp(t, appendConfig, map[string]func(Flags) []byte{"Arg": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"mycmd arg --append=true", "true"},
+		{"mycmd arg --append=", ""},
+		{"mycmd arg --append==", "="},
+		{"mycmd arg --append=a=b", "a=b"},
+		{`mycmd arg --append="a b"`, "a b"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := flags.GetVar("append"); !ok || got != test.want {
+				t.Errorf("GetVar(append) = %q, %t, want %q", got, ok, test.want)
+			}
+		})
+	}
+}
+
+const typedConfig = `
+commands:
+  - label: run
+    arguments:
+      - label: job
+        execFunc: Job
+        options:
+          - label: count
+            short: -c
+            variable:
+              label: count
+          - label: ratio
+            short: -r
+            variable:
+              label: ratio
+          - label: enabled
+            short: -e
+            variable:
+              label: enabled
+          - label: color
+            long: --color
+`
+
+func TestTypedAccessors(t *testing.T) {
+	app, _ := newTestApp(t, typedConfig, map[string]func(Flags) []byte{"Job": output("")}, "")
+	get := map[string]func(Flags, string) (interface{}, error){
+		"GetInt":   func(f Flags, label string) (interface{}, error) { return f.GetInt(label) },
+		"GetFloat": func(f Flags, label string) (interface{}, error) { return f.GetFloat(label) },
+		"GetBool":  func(f Flags, label string) (interface{}, error) { return f.GetBool(label) },
+	}
+	tests := []struct {
+		input  string
+		method string
+		label  string
+		want   interface{}
+		err    string
+	}{
+		{"run job -c 42", "GetInt", "count", 42, ""},
+		{"run job -c -7", "GetInt", "count", -7, ""},
+		{"run job", "GetInt", "count", 0, `missing variable for option "count"`},
+		{"run job -c 4.2", "GetInt", "count", 0, `invalid integer "4.2" for option "count"`},
+		{"run job -r 0.5", "GetFloat", "ratio", 0.5, ""},
+		{"run job -r 3", "GetFloat", "ratio", 3.0, ""},
+		{"run job", "GetFloat", "ratio", 0.0, `missing variable for option "ratio"`},
+		{"run job -r half", "GetFloat", "ratio", 0.0, `invalid number "half" for option "ratio"`},
+		{"run job -e true", "GetBool", "enabled", true, ""},
+		{"run job -e 1", "GetBool", "enabled", true, ""},
+		{"run job -e YES", "GetBool", "enabled", true, ""},
+		{"run job -e false", "GetBool", "enabled", false, ""},
+		{"run job -e 0", "GetBool", "enabled", false, ""},
+		{"run job -e no", "GetBool", "enabled", false, ""},
+		{"run job", "GetBool", "enabled", false, `missing variable for option "enabled"`},
+		{"run job -e maybe", "GetBool", "enabled", false, `invalid boolean "maybe" for option "enabled"`},
+		{"run job --color", "GetBool", "color", true, ""},
+		{"run job --no-color", "GetBool", "color", false, ""},
+		{"run job", "GetBool", "color", false, `option "color" has not been set`},
+	}
+	for _, test := range tests {
+		t.Run(test.method+" "+test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := get[test.method](flags, test.label)
+			if fmt.Sprint(err) != test.err && !(err == nil && test.err == "") {
+				t.Errorf("%s(%s) error = %v, want %q", test.method, test.label, err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("%s(%s) = %v, want %v", test.method, test.label, got, test.want)
+			}
+		})
+	}
+}
+
+const separatorsConfig = `
+longValueSeparators: ["=", ":"]
+commands:
+  - label: log
+    arguments:
+      - label: set
+        execFunc: Set
+        options:
+          - label: level
+            long: --level
+            variable:
+              label: level
+`
+
+func TestLongValueSeparators(t *testing.T) {
+	app, _ := newTestApp(t, separatorsConfig, map[string]func(Flags) []byte{"Set": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"log set --level=debug", "debug"},
+		{"log set --level:debug", "debug"},
+		{"log set --level:", ""},
+		{"log set --level:a=b", "a=b"},
+		{"log set --level=a:b", "a:b"},
+		{`log set --level:"a b"`, "a b"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := flags.GetVar("level"); !ok || got != test.want {
+				t.Errorf("GetVar(level) = %q, %t, want %q", got, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestLongValueSeparatorsInvalid(t *testing.T) {
+	option := `
+commands:
+  - label: log
+    arguments:
+      - label: set
+        options:
+          - label: level
+            long: %s
+`
+	tests := []struct {
+		name       string
+		separators string
+		long       string
+		want       string
+	}{
+		{"empty", `[""]`, "--level", `invalid long value separator ""`},
+		{"delimiter", `[" "]`, "--level", `invalid long value separator " "`},
+		{"in an option long", `["=", ":"]`, "--log:level", `command "log", argument "set", invalid option long "--log:level", contains the long value separator ":"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yaml := testHeader + "longValueSeparators: " + test.separators + fmt.Sprintf(option, test.long)
+			_, err := LoadConfigBytes([]byte(yaml))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+const commandLineConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: quiet
+            short: -q
+          - label: color
+            long: --color
+          - label: number
+            short: -n
+            variable:
+              label: number
+          - label: name
+            long: --name
+            repeatable: true
+            variable:
+              label: name
+`
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		input      string
+		want       string
+		separators string
+	}{
+		{"get all", "get all", ""},
+		{"get all -q -n 5", "get all -n 5 -q", ""},
+		{"get all -n -5 --color", "get all --color -n -5", ""},
+		{"get all --no-color", "get all --no-color", ""},
+		{`get all --name="a b" --name=c`, `get all --name="a b" --name=c`, ""},
+		{`get all --name='say "hi"'`, `get all --name="say \"hi\""`, ""},
+		{`get all --name=`, `get all --name=""`, ""},
+		{`get all -n 'back\slash'`, `get all -n "back\\slash"`, ""},
+		{"get all --name=a --name=b", "get all --name:a --name:b", `[":", "="]`},
+		{"get all --name:a -n 5", "get all --name:a -n 5", `[":"]`},
+		{"get all -qqq", "get all -q -q -q", ""},
+		{"get all -q --color -q --color", "get all --color --color -q -q", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			config := commandLineConfig
+			if test.separators != "" {
+				config = strings.Replace(config, `["="]`, test.separators, 1)
+			}
+			app, _ := newTestApp(t, config, map[string]func(Flags) []byte{"GetAll": output("")}, "")
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			line := flags.CommandLine("get", "all")
+			if line != test.want {
+				t.Errorf("CommandLine() = %q, want %q", line, test.want)
+			}
+
+			// The reconstructed line re-parses to the same flags
+			_, _, reparsed, err := app.Parse(line)
+			if err != nil {
+				t.Fatalf("Parse(%q) = %v", line, err)
+			}
+			if reparsed.String() != flags.String() {
+				t.Errorf("Parse(%q) = %s, want %s", line, reparsed, flags)
+			}
+			for _, label := range []string{"quiet", "color"} {
+				if reparsed.Count(label) != flags.Count(label) {
+					t.Errorf("Parse(%q) Count(%s) = %d, want %d", line, label, reparsed.Count(label), flags.Count(label))
+				}
+			}
+		})
+	}
+}
+
+const defaultConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: log
+    arguments:
+      - label: ""
+        execFunc: Log
+        options:
+          - label: level
+            short: -l
+            long: --level
+            variable:
+              label: level
+              default: info
+          - label: file
+            short: -f
+            long: --file
+            variable:
+              label: file
+`
+
+func TestVariableDefault(t *testing.T) {
+	app, _ := newTestApp(t, defaultConfig, map[string]func(Flags) []byte{"Log": output("")}, "")
+	tests := []struct {
+		name  string
+		input string
+		label string
+		want  string
+		isset bool
+	}{
+		{"provided long", "log --level=debug", "level", "debug", true},
+		{"provided short", "log -l debug", "level", "debug", true},
+		{"omitted value with default", "log --level", "level", "info", true},
+		{"omitted short value with default", "log -l", "level", "info", true},
+		{"omitted short value before option", "log -l -f a", "level", "info", true},
+		{"omitted value without default", "log --file", "file", "", true},
+		{"omitted short value without default", "log -f", "file", "", true},
+		{"option omitted with default", "log", "level", "info", false},
+		{"option omitted without default", "log", "file", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := flags.GetVarOrDefault(test.label); got != test.want {
+				t.Errorf("GetVarOrDefault(%s) = %q, want %q", test.label, got, test.want)
+			}
+			if flags.IsSet(test.label) != test.isset {
+				t.Errorf("IsSet(%s) = %t, want %t", test.label, flags.IsSet(test.label), test.isset)
+			}
+			if got, ok := flags.GetVar(test.label); test.isset && (!ok || got != test.want) {
+				t.Errorf("GetVar(%s) = %q, %t, want %q", test.label, got, ok, test.want)
+			}
+		})
+	}
+}
+
+const passThroughConfig = `
+commands:
+  - label: build
+    arguments:
+      - label: ""
+        execFunc: Build
+        options:
+          - label: quiet
+            short: -q
+`
+
+func TestPassThrough(t *testing.T) {
+	app, _ := newTestApp(t, passThroughConfig, map[string]func(Flags) []byte{"Build": output("")}, "")
+	tests := []struct {
+		input string
+		quiet bool
+		want  []string
+	}{
+		{"build", false, nil},
+		{"build -q", true, nil},
+		{"build --", false, []string{}},
+		{"build -- --verbose --jobs=4", false, []string{"--verbose", "--jobs=4"}},
+		{"build -q -- -q help", true, []string{"-q", "help"}},
+		{`build -- "a b" '' --`, false, []string{"a b", "", "--"}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := flags.PassThrough(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("PassThrough() = %#v, want %#v", got, test.want)
+			}
+			if flags.IsSet("quiet") != test.quiet {
+				t.Errorf("IsSet(quiet) = %t, want %t", flags.IsSet("quiet"), test.quiet)
+			}
+		})
+	}
+}
+
+const terminatorConfig = `
+strictOptions: true
+commands:
+  - label: open
+    arguments:
+      - label: file
+        execFunc: File
+        options:
+          - label: force
+            short: -f
+          - label: mode
+            short: -m
+            variable:
+              label: mode
+              default: read
+      - label: dir
+        execFunc: Dir
+`
+
+func TestTerminator(t *testing.T) {
+	funcs := map[string]func(Flags) []byte{
+		"File": func(flags Flags) []byte {
+			return []byte(fmt.Sprintf("file %s %q\n", flags, flags.PassThrough()))
+		},
+		"Dir": output("dir\n"),
+	}
+	app, _ := newTestApp(t, terminatorConfig, funcs, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"open file -- -report.txt", "file {force: set=false, mode: set=false value=\"read\"} [\"-report.txt\"]\n"},
+		{"open file -f -- -f", "file {force: set=true, mode: set=false value=\"read\"} [\"-f\"]\n"},
+		{"open file -- -x --unknown", "file {force: set=false, mode: set=false value=\"read\"} [\"-x\" \"--unknown\"]\n"},
+		{"open file -m -- -report.txt", "file {force: set=false, mode: set=true value=\"read\"} [\"-report.txt\"]\n"},
+		{"open file -- dir", "file {force: set=false, mode: set=false value=\"read\"} [\"dir\"]\n"},
+		{"open file -- help", "file {force: set=false, mode: set=false value=\"read\"} [\"help\"]\n"},
+		{"open file -x -- -report.txt", "unknown option \"-x\", did you mean \"-f\"?\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const shortValueConfig = `
+commands:
+  - label: set
+    arguments:
+      - label: value
+        execFunc: Value
+        options:
+          - label: apply
+            short: -a
+            variable:
+              label: apply
+              default: read
+          - label: backup
+            short: -b
+          - label: count
+            short: -c
+            variable:
+              label: count
+              required: true
+`
+
+func TestShortOptionValue(t *testing.T) {
+	app, _ := newTestApp(t, shortValueConfig, map[string]func(Flags) []byte{"Value": echo("value")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"set value -a write", "value {apply: set=true value=\"write\", backup: set=false, count: set=false value=\"\"}\n"},
+		{"set value -a -b", "value {apply: set=true value=\"read\", backup: set=true, count: set=false value=\"\"}\n"},
+		{"set value -a -5", "value {apply: set=true value=\"-5\", backup: set=false, count: set=false value=\"\"}\n"},
+		{"set value -a -0.5 -b", "value {apply: set=true value=\"-0.5\", backup: set=true, count: set=false value=\"\"}\n"},
+		{"set value -a", "value {apply: set=true value=\"read\", backup: set=false, count: set=false value=\"\"}\n"},
+		{"set value -c -b", "missing variable \"count\" for option \"count\"\n"},
+		{"set value -c", "missing variable \"count\" for option \"count\"\n"},
+		{"set value -c 3 -b", "value {apply: set=false value=\"read\", backup: set=true, count: set=true value=\"3\"}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const negativeConfig = `
+strictOptions: true
+commands:
+  - label: seek
+    arguments:
+      - label: file
+        execFunc: File
+        options:
+          - label: offset
+            short: -o
+            long: --offset
+            variable:
+              label: offset
+              required: true
+          - label: exact
+            short: -x
+`
+
+func TestNegativeNumbers(t *testing.T) {
+	app, _ := newTestApp(t, negativeConfig, map[string]func(Flags) []byte{"File": echo("file")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"seek file --offset=-5", "file {exact: set=false, offset: set=true value=\"-5\"}\n"},
+		{"seek file -o -5", "file {exact: set=false, offset: set=true value=\"-5\"}\n"},
+		{"seek file -o -2.5 -x", "file {exact: set=true, offset: set=true value=\"-2.5\"}\n"},
+		{"seek file -o -.5", "file {exact: set=false, offset: set=true value=\"-.5\"}\n"},
+		{"seek file -o -x", "missing variable \"offset\" for option \"offset\"\n"},
+		{"seek file -o -y", "missing variable \"offset\" for option \"offset\"\n"},
+		{"seek file -o -5x", "missing variable \"offset\" for option \"offset\"\n"},
+		{"seek file -5", "invalid text \"-5\" detected\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsNegativeNumber(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"-5", true},
+		{"-0.5", true},
+		{"-.5", true},
+		{"-1e3", true},
+		{"5", false},
+		{"-", false},
+		{"-x", false},
+		{"-5x", false},
+		{"--5", false},
+		{"-inf", false},
+	}
+	for _, test := range tests {
+		if got := isNegativeNumber(test.token); got != test.want {
+			t.Errorf("isNegativeNumber(%q) = %t, want %t", test.token, got, test.want)
+		}
+	}
+}
+
+const countConfig = `
+commands:
+  - label: run
+    arguments:
+      - label: job
+        execFunc: Job
+        options:
+          - label: verbose
+            short: -v
+            long: --verbose
+          - label: quiet
+            short: -q
+`
+
+func TestCount(t *testing.T) {
+	var flags Flags
+	app, _ := newTestApp(t, countConfig, map[string]func(Flags) []byte{"Job": func(f Flags) []byte {
+		flags = f
+		return nil
+	}}, "")
+	tests := []struct {
+		input   string
+		verbose int
+		quiet   int
+	}{
+		{"run job", 0, 0},
+		{"run job -v", 1, 0},
+		{"run job -vvv", 3, 0},
+		{"run job --verbose --verbose", 2, 0},
+		{"run job -vq -v --verbose", 3, 1},
+		{"run job -vqv", 2, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			flags = Flags{}
+			app.Execute(test.input)
+			if got := flags.Count("verbose"); got != test.verbose {
+				t.Errorf("Count(verbose) = %d, want %d", got, test.verbose)
+			}
+			if got := flags.Count("quiet"); got != test.quiet {
+				t.Errorf("Count(quiet) = %d, want %d", got, test.quiet)
+			}
+			if got := flags.Count("missing"); got != 0 {
+				t.Errorf("Count(missing) = %d, want 0", got)
+			}
+		})
+	}
+}
+
+const stringConfig = `
+commands:
+  - label: deploy
+    arguments:
+      - label: app
+        execFunc: App
+        options:
+          - label: quiet
+            short: -q
+          - label: name
+            short: -n
+            variable:
+              label: name
+          - label: region
+            short: -r
+            variable:
+              label: region
+              default: eu
+          - label: token
+            short: -t
+            secret: true
+            variable:
+              label: token
+          - label: tag
+            long: --tag
+            repeatable: true
+            variable:
+              label: tag
+`
+
+func TestFlagsString(t *testing.T) {
+	app, _ := newTestApp(t, stringConfig, map[string]func(Flags) []byte{"App": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"deploy app", `{name: set=false value="", quiet: set=false, region: set=false value="eu", tag: set=false value="", token: set=false value="***"}`},
+		{"deploy app -q -n \"my app\" -r us", `{name: set=true value="my app", quiet: set=true, region: set=true value="us", tag: set=false value="", token: set=false value="***"}`},
+		{"deploy app -t hunter2 --tag=a --tag=b", `{name: set=false value="", quiet: set=false, region: set=false value="eu", tag: set=true values=["a" "b"], token: set=true value="***"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := flags.String(); got != test.want {
+				t.Errorf("String() = %s, want %s", got, test.want)
+			}
+			if got := fmt.Sprintf("%v", flags); got != test.want {
+				t.Errorf("Sprintf(%%v) = %s, want %s", got, test.want)
+			}
+		})
+	}
+	if got := (Flags{}).String(); got != "{}" {
+		t.Errorf("String() = %s for empty flags, want {}", got)
+	}
+}
+
+func TestNewFlags(t *testing.T) {
+	flags := NewFlags(map[string]string{"name": "web", "region": "", "tag": "v1"}, map[string]bool{"quiet": true, "color": false, "tag": false})
+	tests := []struct {
+		label  string
+		exists bool
+		set    bool
+		value  string
+		hasVar bool
+	}{
+		{"name", true, true, "web", true},
+		{"region", true, true, "", true},
+		{"tag", true, false, "", false},
+		{"quiet", true, true, "", false},
+		{"color", true, false, "", false},
+		{"missing", false, false, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			if got := flags.Exists(test.label); got != test.exists {
+				t.Errorf("Exists(%s) = %t, want %t", test.label, got, test.exists)
+			}
+			if got := flags.IsSet(test.label); got != test.set {
+				t.Errorf("IsSet(%s) = %t, want %t", test.label, got, test.set)
+			}
+			if value, ok := flags.GetVar(test.label); value != test.value || ok != test.hasVar {
+				t.Errorf("GetVar(%s) = %q, %t, want %q, %t", test.label, value, ok, test.value, test.hasVar)
+			}
+			if count, want := flags.Count(test.label), map[bool]int{true: 1}[test.set]; count != want {
+				t.Errorf("Count(%s) = %d, want %d", test.label, count, want)
+			}
+		})
+	}
+	if got, want := flags.String(), `{color: set=false, name: set=true value="web", quiet: set=true, region: set=true value="", tag: set=false value="v1"}`; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	if got := NewFlags(nil, nil).String(); got != "{}" {
+		t.Errorf("String() = %s for no options, want {}", got)
+	}
+}
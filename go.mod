@@ -2,4 +2,9 @@ module github.com/chrismalcolm/go-cli
 
 go 1.20
 
-require gopkg.in/yaml.v2 v2.4.0
+require (
+	golang.org/x/term v0.13.0
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require golang.org/x/sys v0.13.0 // indirect
@@ -2,70 +2,274 @@ package cli
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
-// createHelp is a function for generating the global help function
+// helpFormat is the format of the help. If color is true, the labels are in
+// colour. If width is greater than zero, the help messages are wrapped such
+// that the lines are no longer than the width. If sorted is true, the commands
+// and options are listed in alphabetical order.
+type helpFormat struct {
+	color  bool
+	width  int
+	sorted bool
+}
+
+// tabWidth is the number of columns a tab is assumed to take up in the help
+const tabWidth = 8
+
+// wrap wraps the help message to the width of the format, where the help
+// message follows a tab and the given number of columns on the first line.
+// Any continuation lines are indented to align with the first line. If the
+// width leaves too little space for the help message, it isn't wrapped.
+func (format helpFormat) wrap(helpMsg string, column int) string {
+	available := format.width - tabWidth - column
+	if format.width <= 0 || available < 20 || len(helpMsg) <= available {
+		return helpMsg
+	}
+	indent := "\n\t" + strings.Repeat(" ", column)
+
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(helpMsg, "\n") {
+		var line string
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > available {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, indent)
+}
+
+// createHelp is a function for generating the global help function.
+// The global help only lists the commands with a summary of each,
+// the full detail of a command is given by the command help.
 func (config Config) createHelp() func(Flags) []byte {
-	return func(flags Flags) (output []byte) {
-		output = make([]byte, 0)
-		for _, command := range config.Commands {
-			output = append(output, command.help(flags)...)
+	return func(flags Flags) []byte {
+		return []byte(config.helpSummary(flags.format))
+	}
+}
+
+// helpSummary returns a summary of each of the commands in the format
+func (config Config) helpSummary(format helpFormat) string {
+	commands := config.HelpModel()
+
+	// Format for the padding
+	var longestLabelLength int
+	for _, command := range commands {
+		if longestLabelLength < len(command.friendlyName()) {
+			longestLabelLength = len(command.friendlyName())
+		}
+	}
+	paddingStr := fmt.Sprintf("%%-%ds", longestLabelLength)
+
+	// List each command with its summary and correct padding,
+	// under the header of the group of the command
+	var desc string
+	for _, group := range commandGroups(commands, format.sorted) {
+		desc += fmt.Sprintf("\n%s:\n\n", group.header)
+		for _, command := range group.commands {
+			name := colorize(fmt.Sprintf(paddingStr, command.friendlyName()), ansiCyan, format.color)
+			desc += fmt.Sprintf("\t%s %s\n", name, format.wrap(command.summary(config.HelpSummaryLength), longestLabelLength+1))
+		}
+	}
+	desc += fmt.Sprintf("\nUse \"<command> %s\" for more information about a command.\n", config.HelpCmd[0])
+	return desc
+}
+
+// commandGroup is a group of commands listed under a header in the global help
+type commandGroup struct {
+	header   string
+	commands []HelpCommand
+}
+
+// commandGroups returns the groups of the commands listed in the global help.
+// If none of the commands have a category, all the commands are listed in
+// order under a single header, sorted by label if sorted is true. Otherwise,
+// the commands are grouped by their category, sorted by category then label,
+// with any commands without a category listed last.
+func commandGroups(commands []HelpCommand, sorted bool) []commandGroup {
+	categories := make(map[string][]HelpCommand)
+	for _, command := range commands {
+		categories[command.Category] = append(categories[command.Category], command)
+	}
+	if commands, ok := categories[""]; ok && len(categories) == 1 {
+		if sorted {
+			sort.SliceStable(commands, func(i, j int) bool {
+				return commands[i].Label < commands[j].Label
+			})
+		}
+		return []commandGroup{{header: "Commands", commands: commands}}
+	}
+
+	names := make([]string, 0, len(categories))
+	for category := range categories {
+		if category != "" {
+			names = append(names, category)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := categories[""]; ok {
+		names = append(names, "")
+	}
+
+	groups := make([]commandGroup, 0, len(names))
+	for _, category := range names {
+		commands := categories[category]
+		sort.SliceStable(commands, func(i, j int) bool {
+			return commands[i].Label < commands[j].Label
+		})
+		header := category
+		if header == "" {
+			header = "Other Commands"
+		}
+		groups = append(groups, commandGroup{header: header, commands: commands})
+	}
+	return groups
+}
+
+// friendlyName returns the friendly name for the command.
+// Namely, it returns the command label followed by any
+// aliases in brackets, e.g. "delete (rm, del)".
+func (cmd HelpCommand) friendlyName() string {
+	if len(cmd.Aliases) == 0 {
+		return cmd.Label
+	}
+	return fmt.Sprintf("%s (%s)", cmd.Label, strings.Join(cmd.Aliases, ", "))
+}
+
+// summary returns the first line of the help message for the command,
+// truncated to the given length if it is not zero. If the command has
+// no help message, the arguments of the command are listed instead.
+func (cmd HelpCommand) summary(length int) string {
+	summary := strings.SplitN(cmd.HelpMsg, "\n", 2)[0]
+	if summary == "" {
+		summary = strings.SplitN(describeArguments(cmd.Arguments, helpFormat{}), "\n", 2)[0]
+		if summary == "[]" {
+			summary = ""
+		}
+	}
+	if runes := []rune(summary); length > 0 && len(runes) > length {
+		if length <= 3 {
+			return string(runes[:length])
 		}
-		return output
+		summary = string(runes[:length-3]) + "..."
 	}
+	return summary
 }
 
 // createHelp is a function for generating the command help function
 func (cmd Command) createHelp() func(Flags) []byte {
-	return func(_ Flags) []byte {
-		return []byte(cmd.helpCmd())
+	return func(flags Flags) []byte {
+		return []byte(cmd.helpModel().helpCmd(flags.format))
 	}
 }
 
-// createHelp is a function for generating the argument help function
-func (cmd Command) createArgHelp(arg Argument) func(Flags) []byte {
-	return func(_ Flags) []byte {
-		return []byte(cmd.helpArg(arg))
+// createArgHelp is a function for generating the argument help function.
+// The prefix is the command label followed by the labels of any parent
+// arguments.
+func createArgHelp(prefix string, arg Argument) func(Flags) []byte {
+	return func(flags Flags) []byte {
+		return []byte(helpArgument(prefix, arg.helpModel(), flags.format))
 	}
 }
 
-// helpCmd returns information on the usage of the command
-func (cmd Command) helpCmd() string {
-	desc := fmt.Sprintf(
-		"\nUsage: %s\n\n%s %s\n",
-		cmd.Label,
-		cmd.Label,
-		describeArguments(cmd.Arguments),
-	)
+// helpCmd returns information on the usage of the command in the format
+func (cmd HelpCommand) helpCmd(format helpFormat) string {
+	desc := fmt.Sprintf("\nUsage: %s\n\n", cmd.Label)
+	if len(cmd.Aliases) > 0 {
+		desc += fmt.Sprintf("Aliases: %s\n\n", strings.Join(cmd.Aliases, ", "))
+	}
+	if cmd.HelpMsg != "" {
+		desc += cmd.HelpMsg + "\n\n"
+	}
+	desc += fmt.Sprintf("%s %s\n", cmd.Label, describeArguments(cmd.Arguments, format))
 	for _, arg := range cmd.Arguments {
-		desc += fmt.Sprintf("%s %s", cmd.Label, arg.helpArg())
+		desc += fmt.Sprintf("%s %s", cmd.Label, arg.helpArg(format))
 	}
 	return desc
 }
 
-// helpArg returns information on the usage of the argument using the command
-func (cmd Command) helpArg(arg Argument) string {
-	return fmt.Sprintf(
-		"\nUsage: %s %s\n\n%s %s",
-		cmd.Label,
-		arg.friendlyName(),
-		cmd.Label,
-		arg.helpArg(),
-	)
+// usage returns a single line synopsis of the usage of the command
+func (cmd HelpCommand) usage() string {
+	synopsis := strings.SplitN(describeArguments(cmd.Arguments, helpFormat{}), "\n", 2)[0]
+	if synopsis == "[]" {
+		return fmt.Sprintf("Usage: %s", cmd.Label)
+	}
+	return fmt.Sprintf("Usage: %s %s", cmd.Label, synopsis)
+}
+
+// usageArg returns a single line synopsis of the usage of the argument using
+// the command, where the path is the labels of the argument and any parents
+func (cmd HelpCommand) usageArg(path string, arg HelpArgument) string {
+	desc := "Usage: " + cmd.Label
+	if path != "" {
+		desc += " " + path
+	}
+	synopsis := strings.SplitN(describeOptions(arg.Options, helpFormat{}), "\n", 2)[0]
+	if len(arg.Arguments) > 0 {
+		synopsis = strings.SplitN(describeArguments(arg.Arguments, helpFormat{}), "\n", 2)[0]
+	}
+	if synopsis != "" && synopsis != "[]" {
+		desc += " " + synopsis
+	}
+	return desc
+}
+
+// helpArgument returns information on the usage of the argument, where the
+// prefix is the command label followed by the labels of any parent arguments.
+// For an argument with child arguments, the usage of each child is given.
+// The information is given in the format.
+func helpArgument(prefix string, arg HelpArgument, format helpFormat) string {
+	if len(arg.Arguments) == 0 {
+		return fmt.Sprintf(
+			"\nUsage: %s %s\n\n%s %s",
+			prefix,
+			arg.friendlyName(),
+			prefix,
+			arg.helpArg(format),
+		)
+	}
+
+	prefix = strings.TrimRight(prefix+" "+arg.Label, " ")
+	desc := fmt.Sprintf("\nUsage: %s\n\n", prefix)
+	if arg.HelpMsg != "" {
+		desc += arg.HelpMsg + "\n\n"
+	}
+	desc += fmt.Sprintf("%s %s\n", prefix, describeArguments(arg.Arguments, format))
+	for _, child := range arg.Arguments {
+		desc += fmt.Sprintf("%s %s", prefix, child.helpArg(format))
+	}
+	return desc
 }
 
-// help returns information on the usage of the argument
-func (arg Argument) helpArg() string {
+// help returns information on the usage of the argument, or
+// of its child arguments if it has any
+func (arg HelpArgument) helpArg(format helpFormat) string {
+	if len(arg.Arguments) > 0 {
+		return fmt.Sprintf(
+			"%s %s",
+			arg.friendlyName(),
+			describeArguments(arg.Arguments, format),
+		)
+	}
 	return fmt.Sprintf(
 		"%s %s\n",
 		arg.friendlyName(),
-		describeOptions(arg.Options),
+		describeOptions(arg.Options, format),
 	)
 }
 
 // describeArguments describes the arguments using command syntax convention
-func describeArguments(arguments []Argument) string {
+// in the format
+func describeArguments(arguments []HelpArgument, format helpFormat) string {
 
 	// In command syntax convention, arguments are displayed
 	// in a list separated by (|)
@@ -104,7 +308,7 @@ func describeArguments(arguments []Argument) string {
 	// List each argument with its help message and correct padding
 	for _, argument := range arguments {
 		label = argument.friendlyName()
-		desc += fmt.Sprintf("\t"+paddingStr+" %s\n", label, argument.HelpMsg)
+		desc += fmt.Sprintf("\t%s %s\n", colorize(fmt.Sprintf(paddingStr, label), ansiCyan, format.color), format.wrap(argument.HelpMsg, longestLabelLength+1))
 	}
 	return desc
 }
@@ -112,7 +316,7 @@ func describeArguments(arguments []Argument) string {
 // friendlyName returns the friendly name for the argument.
 // Namely, it returns the argument label unless it is empty,
 // in that case it returns "(no arguments)".
-func (arg Argument) friendlyName() string {
+func (arg HelpArgument) friendlyName() string {
 	if arg.Label == "" {
 		return "(no arguments)"
 	}
@@ -120,7 +324,13 @@ func (arg Argument) friendlyName() string {
 }
 
 // describeOptions describes the options using command syntax convention
-func describeOptions(options []Option) string {
+// in the format
+func describeOptions(options []HelpOption, format helpFormat) string {
+
+	// Options are listed in alphabetical order if the format is sorted
+	if format.sorted {
+		options = sortOptions(options)
+	}
 
 	// In command syntax convention, options are split into 4 distinct categories.
 	// They are split on whether they have a short name or not and split on
@@ -132,11 +342,17 @@ func describeOptions(options []Option) string {
 	// characters.
 	var reqShort, reqLong, optShort, optLong string
 	for _, option := range options {
-		if option.Variable != nil && option.Variable.Required {
+		if option.Required && option.Variable == "" {
 			if option.Short != "" {
-				reqShort += fmt.Sprintf("%s %s ", option.Short, option.Variable.Label)
+				reqShort += fmt.Sprintf("%s ", option.Short)
 			} else {
-				reqLong += fmt.Sprintf("%s=%s ", option.Long, option.Variable.Label)
+				reqLong += fmt.Sprintf("%s ", option.Long)
+			}
+		} else if option.Required {
+			if option.Short != "" {
+				reqShort += fmt.Sprintf("%s %s ", option.Short, option.placeholder())
+			} else {
+				reqLong += fmt.Sprintf("%s=%s ", option.Long, option.placeholder())
 			}
 		} else {
 			if option.Short != "" {
@@ -182,7 +398,58 @@ func describeOptions(options []Option) string {
 	// List each option with its help message and correct padding
 	paddingStr := fmt.Sprintf("%%-%ds", longestLongLength)
 	for _, option := range options {
-		desc += fmt.Sprintf("\t%s "+paddingStr+" %s\n", option.Short, option.Long, option.HelpMsg)
+		helpMsg := option.HelpMsg
+		if len(option.Choices) > 0 {
+			helpMsg = strings.TrimLeft(fmt.Sprintf("%s (%s)", helpMsg, option.placeholder()), " ")
+		}
+		if option.lengthHint() != "" {
+			helpMsg = strings.TrimLeft(fmt.Sprintf("%s (%s)", helpMsg, option.lengthHint()), " ")
+		}
+		short := colorize(option.Short, ansiCyan, format.color)
+		long := colorize(fmt.Sprintf(paddingStr, option.Long), ansiCyan, format.color)
+		desc += fmt.Sprintf("\t%s %s %s\n", short, long, format.wrap(helpMsg, len(option.Short)+longestLongLength+2))
 	}
 	return desc
 }
+
+// sortOptions returns a copy of the options sorted alphabetically by their
+// long name, or their short name if they don't have a long name, ignoring
+// the dashes (-) before the names.
+func sortOptions(options []HelpOption) []HelpOption {
+	sorted := append([]HelpOption{}, options...)
+	name := func(option HelpOption) string {
+		if option.Long != "" {
+			return strings.TrimLeft(option.Long, "-")
+		}
+		return strings.TrimLeft(option.Short, "-")
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return name(sorted[i]) < name(sorted[j])
+	})
+	return sorted
+}
+
+// placeholder returns the placeholder for the variable of the option in help
+// messages. Namely, it returns the variable label unless it has choices, in
+// that case it returns the choices separated by (|).
+func (opt HelpOption) placeholder() string {
+	if len(opt.Choices) > 0 {
+		return strings.Join(opt.Choices, "|")
+	}
+	return opt.Variable
+}
+
+// lengthHint returns a description of the minimum and maximum length
+// for the variable of the option in help messages, or empty if there
+// are neither.
+func (opt HelpOption) lengthHint() string {
+	switch {
+	case opt.MinLength > 0 && opt.MaxLength > 0:
+		return fmt.Sprintf("%d-%d characters", opt.MinLength, opt.MaxLength)
+	case opt.MinLength > 0:
+		return fmt.Sprintf("at least %d characters", opt.MinLength)
+	case opt.MaxLength > 0:
+		return fmt.Sprintf("at most %d characters", opt.MaxLength)
+	}
+	return ""
+}
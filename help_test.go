@@ -0,0 +1,356 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const helpConfig = `
+commands:
+  - label: get
+    help: "Get the things\nThe things are fetched from the store."
+    arguments:
+      - label: all
+        help: Get all the things
+        execFunc: GetAll
+        options:
+          - label: number
+            short: -n
+            help: The number of things to get
+            variable:
+              label: count
+  - label: set
+    arguments:
+      - label: name
+        help: Set the name
+        execFunc: SetName
+`
+
+func TestHelpSummaryOnly(t *testing.T) {
+	app, _ := newTestApp(t, helpConfig, map[string]func(Flags) []byte{"GetAll": output(""), "SetName": output("")}, "")
+	help := string(app.Execute("help"))
+	for _, want := range []string{"get", "Get the things", "set", `Use "<command> help" for more information about a command.`} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected %q in the help\n%s", want, help)
+		}
+	}
+	for _, detail := range []string{"The things are fetched", "The number of things to get", "-n", "count", "Get all the things"} {
+		if strings.Contains(help, detail) {
+			t.Errorf("expected the detail %q to be left out of the top-level help\n%s", detail, help)
+		}
+	}
+	commandHelp := string(app.Execute("get help"))
+	for _, want := range []string{"The things are fetched", "The number of things to get", "-n"} {
+		if !strings.Contains(commandHelp, want) {
+			t.Errorf("expected %q in the command help\n%s", want, commandHelp)
+		}
+	}
+}
+
+func TestCommandSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		command Command
+		length  int
+		want    string
+	}{
+		{"first line", Command{HelpMsg: "Get the things\nMore detail"}, 0, "Get the things"},
+		{"truncated", Command{HelpMsg: "Get the things"}, 10, "Get the..."},
+		{"not truncated", Command{HelpMsg: "Get the things"}, 14, "Get the things"},
+		{"very short", Command{HelpMsg: "Get the things"}, 3, "Get"},
+		{"arguments", Command{Arguments: []Argument{{Label: "all"}, {Label: "none"}}}, 0, "all|none"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.command.helpModel().summary(test.length); got != test.want {
+				t.Errorf("summary(%d) = %q, want %q", test.length, got, test.want)
+			}
+		})
+	}
+}
+
+const usageConfig = `
+longValueSeparators: ["="]
+strictOptions: true
+commands:
+  - label: start
+    arguments:
+      - label: service
+        execFunc: Service
+        options:
+          - label: verbose
+            short: -v
+          - label: target
+            long: --target
+            required: true
+            variable:
+              label: NAME
+      - label: job
+        execFunc: Job
+`
+
+func TestUsageOnError(t *testing.T) {
+	funcs := map[string]func(Flags) []byte{"Service": output("started\n"), "Job": output("started\n")}
+	tests := []struct {
+		input   string
+		enabled bool
+		want    string
+	}{
+		{"start service -x --target=web", true, "unknown option \"-x\", did you mean \"-v\"?\nUsage: start service --target=NAME -v\n"},
+		{"start service -x --target=web", false, "unknown option \"-x\", did you mean \"-v\"?\n"},
+		{"start service -v", true, "missing required options \"target\"\nUsage: start service --target=NAME -v\n"},
+		{"start", true, "invalid use of the \"start\" command, no valid argument provided\nUsage: start service|job\n"},
+		{"start nothing", false, "invalid use of the \"start\" command, no valid argument provided\n"},
+		{"stop service", true, "unable to find command \"stop\"\n"},
+		{"start service --target=web", true, "started\n"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s enabled=%t", test.input, test.enabled), func(t *testing.T) {
+			app, _ := newTestApp(t, usageConfig, funcs, "")
+			app.currentConfig().UsageOnError = test.enabled
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestUsageOnErrorNested(t *testing.T) {
+	app, _ := newTestApp(t, "usageOnError: true\n"+nestedConfig, map[string]func(Flags) []byte{"Origin": output(""), "Upstream": output(""), "List": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"remote", "invalid use of the \"remote\" command, no valid argument provided\nUsage: remote add|list\n"},
+		{"remote add", "invalid use of the \"remote add\" command, no valid argument provided\nUsage: remote add origin|upstream\n"},
+		{"remote add origin upstream", "ambiguous use of the \"remote add\" command, multiple arguments provided \"origin\", \"upstream\"\nUsage: remote add origin|upstream\n"},
+		{"remote list extra", "invalid text \"extra\" detected\nUsage: remote list\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const searchConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: search
+    arguments:
+      - label: docs
+        execFunc: Docs
+        options:
+          - label: query
+            short: -q
+            long: --query
+            variable:
+              label: query
+`
+
+func TestHelpSuffix(t *testing.T) {
+	app, _ := newTestApp(t, searchConfig, map[string]func(Flags) []byte{"Docs": echo("docs")}, "")
+	help := "\nUsage: search docs\n\nsearch docs -q\n\t-q --query \n\n"
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"search docs --query=needhelp", "docs {query: set=true value=\"needhelp\"}\n"},
+		{"search docs -q needhelp", "docs {query: set=true value=\"needhelp\"}\n"},
+		{"search docs --query=help", "docs {query: set=true value=\"help\"}\n"},
+		{"search docshelp", "invalid use of the \"search\" command, no valid argument provided\n"},
+		{"search docs help", help},
+		{"search docs -q x help", help},
+		{"search docs\thelp", help},
+		{"search docs help  ", help},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		column int
+		msg    string
+		want   string
+	}{
+		{"fits", 80, 10, "The number of things to get", "The number of things to get"},
+		{"wrapped", 40, 4, "The number of things to get from the store at once", "The number of things to get\n\t    from the store at once"},
+		{"wrapped twice", 40, 4, "one two three four five six seven eight nine ten eleven twelve", "one two three four five six\n\t    seven eight nine ten eleven\n\t    twelve"},
+		{"long word", 40, 4, "a supercalifragilisticexpialidocious-word b", "a\n\t    supercalifragilisticexpialidocious-word\n\t    b"},
+		{"paragraphs", 40, 4, "The number of things to get\nfrom the store at once with more", "The number of things to get\n\t    from the store at once with\n\t    more"},
+		{"no width", 0, 4, "The number of things to get from the store at once", "The number of things to get from the store at once"},
+		{"too narrow", 30, 4, "The number of things to get from the store at once", "The number of things to get from the store at once"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := (helpFormat{width: test.width}).wrap(test.msg, test.column); got != test.want {
+				t.Errorf("wrap(%q, %d) = %q, want %q", test.msg, test.column, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHelpWidth(t *testing.T) {
+	config := `
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: number
+            short: -n
+            long: --number
+            help: The number of things to get from the store, which must be a positive number no greater than the size of the store
+`
+	for _, width := range []int{40, 60, 100} {
+		t.Run(fmt.Sprint(width), func(t *testing.T) {
+			app, _ := newTestApp(t, fmt.Sprintf("helpWidth: %d\n", width)+config, map[string]func(Flags) []byte{"GetAll": output("")}, "")
+			help := string(app.Execute("get all help"))
+			lines := strings.Split(strings.TrimSpace(help), "\n")
+			first := -1
+			for i, line := range lines {
+				if strings.Contains(line, "--number") {
+					first = i
+				}
+				if got := len(strings.ReplaceAll(line, "\t", strings.Repeat(" ", tabWidth))); got > width {
+					t.Errorf("line %q is %d columns, want at most %d", line, got, width)
+				}
+			}
+			if first == -1 {
+				t.Fatalf("expected the option in the help\n%s", help)
+			}
+
+			// Continuation lines are aligned with the first line of the help message
+			column := strings.Index(lines[first], "The")
+			for _, line := range lines[first+1:] {
+				if indent := len(line) - len(strings.TrimLeft(line, "\t ")); indent != column {
+					t.Errorf("line %q is indented %d, want %d to align with %q", line, indent, column, lines[first])
+				}
+			}
+			if width < 100 && first == len(lines)-1 {
+				t.Errorf("expected the help message to be wrapped\n%s", help)
+			}
+		})
+	}
+}
+
+const categoryConfig = `
+commands:
+  - label: push
+    category: Remote
+    help: Push changes
+    arguments:
+      - label: all
+        execFunc: Noop
+  - label: status
+    help: Show status
+    arguments:
+      - label: all
+        execFunc: Noop
+  - label: fetch
+    category: Remote
+    help: Fetch changes
+    arguments:
+      - label: all
+        execFunc: Noop
+  - label: add
+    category: Files
+    help: Add files
+    arguments:
+      - label: all
+        execFunc: Noop
+`
+
+func TestCategories(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"grouped", categoryConfig, "\nFiles:\n\n\tadd    Add files\n\nRemote:\n\n\tfetch  Fetch changes\n\tpush   Push changes\n\nOther Commands:\n\n\tstatus Show status\n\n"},
+		{"all categorized", strings.Replace(categoryConfig, "help: Show status", "category: Files\n    help: Show status", 1), "\nFiles:\n\n\tadd    Add files\n\tstatus Show status\n\nRemote:\n\n\tfetch  Fetch changes\n\tpush   Push changes\n\n"},
+		{"uncategorized", strings.NewReplacer("category: Remote\n    ", "", "category: Files\n    ", "").Replace(categoryConfig), "\nCommands:\n\n\tpush   Push changes\n\tstatus Show status\n\tfetch  Fetch changes\n\tadd    Add files\n\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, _ := newTestApp(t, test.config, map[string]func(Flags) []byte{"Noop": output("")}, "")
+			want := test.want + "Use \"<command> help\" for more information about a command.\n"
+			if got := string(app.Execute("help")); got != want {
+				t.Errorf("Execute(help) = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+const scrambledConfig = `
+commands:
+  - label: stop
+    help: Stop the service
+    arguments:
+      - label: service
+        execFunc: Noop
+        options:
+          - label: timeout
+            long: --timeout
+            help: The timeout
+          - label: force
+            short: -f
+            help: Force it
+          - label: all
+            short: -x
+            long: --all
+            help: All of them
+  - label: build
+    help: Build the service
+    arguments:
+      - label: service
+        execFunc: Noop
+`
+
+func TestSortHelp(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted bool
+		input  string
+		want   string
+	}{
+		{"commands unsorted", false, "help", "\nCommands:\n\n\tstop  Stop the service\n\tbuild Build the service\n\n"},
+		{"commands sorted", true, "help", "\nCommands:\n\n\tbuild Build the service\n\tstop  Stop the service\n\n"},
+		{"options unsorted", false, "stop help", "-fx --timeout\n\t --timeout The timeout\n\t-f           Force it\n\t-x --all     All of them\n\n"},
+		{"options sorted", true, "stop help", "-xf --timeout\n\t-x --all     All of them\n\t-f           Force it\n\t --timeout The timeout\n\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := scrambledConfig
+			if test.sorted {
+				config = "sortHelp: true\n" + config
+			}
+			app, _ := newTestApp(t, config, map[string]func(Flags) []byte{"Noop": output("")}, "")
+			if got := string(app.Execute(test.input)); !strings.Contains(got, test.want) {
+				t.Errorf("Execute(%q) = %q, want it to contain %q", test.input, got, test.want)
+			}
+		})
+	}
+
+	// Sorting the help doesn't change the order of the config
+	config := loadTestConfig(t, "sortHelp: true\n"+scrambledConfig)
+	app := NewWithIO(config, strings.NewReader(""), io.Discard)
+	app.Execute("help")
+	app.Execute("stop help")
+	if config.Commands[0].Label != "stop" || config.Commands[0].Arguments[0].Options[0].Label != "timeout" {
+		t.Errorf("expected the help to leave the config in declaration order, got %+v", config.Commands)
+	}
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testHeader is prepended to the configs loaded in tests, providing the
+// settings every config requires.
+const testHeader = `
+exitCmd: exit
+helpCmd: help
+initFunc: Init
+exitFunc: Exit
+`
+
+// loadTestConfig loads the config from the yaml, following the test header.
+func loadTestConfig(t testing.TB, config string) *Config {
+	t.Helper()
+	c, err := LoadConfigBytes([]byte(testHeader + config))
+	if err != nil {
+		t.Fatalf("unable to load config: %v", err)
+	}
+	return c
+}
+
+// output returns an executable which returns the output
+func output(s string) func(Flags) []byte {
+	return func(Flags) []byte { return []byte(s) }
+}
+
+// echo returns an executable which returns the name followed by the flags,
+// so that tests can check which executable ran with which flags.
+func echo(name string) func(Flags) []byte {
+	return func(flags Flags) []byte { return []byte(name + " " + flags.String() + "\n") }
+}
+
+// newTestApp loads the config from the yaml and maps the functions to its
+// execFuncs, along with empty init and exit functions, returning an App
+// which reads from in and writes to the returned buffer.
+func newTestApp(t testing.TB, config string, funcs map[string]func(Flags) []byte, in string) (*App, *bytes.Buffer) {
+	t.Helper()
+	c := loadTestConfig(t, config)
+	mapped := map[string]func(Flags) []byte{"Init": output(""), "Exit": output("")}
+	for name, fn := range funcs {
+		mapped[name] = fn
+	}
+	if err := c.WithFuncs(mapped); err != nil {
+		t.Fatalf("unable to map functions: %v", err)
+	}
+	out := new(bytes.Buffer)
+	return NewWithIO(c, strings.NewReader(in), out), out
+}
+
+// parseTest parses the input with the app, returning the dump of the flags,
+// or the error message if the input fails to parse.
+func parseTest(app *App, input string) string {
+	_, _, flags, err := app.Parse(input)
+	if err != nil {
+		return err.Error()
+	}
+	return flags.String()
+}
@@ -0,0 +1,107 @@
+package cli
+
+// HelpCommand is the help for a command, as structured data
+// rather than formatted text, e.g. for rendering in a custom UI.
+type HelpCommand struct {
+	Label     string         `yaml:"label" json:"label"`
+	Aliases   []string       `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	HelpMsg   string         `yaml:"help,omitempty" json:"help,omitempty"`
+	Category  string         `yaml:"category,omitempty" json:"category,omitempty"`
+	Arguments []HelpArgument `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+}
+
+// HelpArgument is the help for an argument of a command.
+// An empty label is an argument for invoking the command
+// without any arguments.
+type HelpArgument struct {
+	Label     string         `yaml:"label" json:"label"`
+	HelpMsg   string         `yaml:"help,omitempty" json:"help,omitempty"`
+	Options   []HelpOption   `yaml:"options,omitempty" json:"options,omitempty"`
+	Arguments []HelpArgument `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+}
+
+// HelpOption is the help for an option of an argument. The variable
+// is the label of the variable for the option, or empty if the option
+// doesn't have a variable. The option is required if either the option
+// or its variable is required.
+type HelpOption struct {
+	Label     string   `yaml:"label" json:"label"`
+	Short     string   `yaml:"short,omitempty" json:"short,omitempty"`
+	Long      string   `yaml:"long,omitempty" json:"long,omitempty"`
+	HelpMsg   string   `yaml:"help,omitempty" json:"help,omitempty"`
+	Required  bool     `yaml:"required,omitempty" json:"required,omitempty"`
+	Variable  string   `yaml:"variable,omitempty" json:"variable,omitempty"`
+	Default   string   `yaml:"default,omitempty" json:"default,omitempty"`
+	Choices   []string `yaml:"choices,omitempty" json:"choices,omitempty"`
+	MinLength int      `yaml:"minLength,omitempty" json:"minLength,omitempty"`
+	MaxLength int      `yaml:"maxLength,omitempty" json:"maxLength,omitempty"`
+}
+
+// HelpModel returns the help for each of the commands as structured data.
+// The help printed by the CLI is formatted from the same data, and the
+// defaults of secret options are masked.
+func (config *Config) HelpModel() []HelpCommand {
+	commands := make([]HelpCommand, 0, len(config.Commands))
+	for _, command := range config.Commands {
+		commands = append(commands, command.helpModel())
+	}
+	return commands
+}
+
+// helpModel returns the help for the command as structured data
+func (cmd Command) helpModel() HelpCommand {
+	return HelpCommand{
+		Label:     cmd.Label,
+		Aliases:   append([]string{}, cmd.Aliases...),
+		HelpMsg:   cmd.HelpMsg,
+		Category:  cmd.Category,
+		Arguments: helpArguments(cmd.Arguments),
+	}
+}
+
+// helpArguments returns the help for each of the arguments as structured data
+func helpArguments(arguments []Argument) []HelpArgument {
+	helpArgs := make([]HelpArgument, 0, len(arguments))
+	for _, argument := range arguments {
+		helpArgs = append(helpArgs, argument.helpModel())
+	}
+	return helpArgs
+}
+
+// helpModel returns the help for the argument, and recursively
+// for any child arguments, as structured data
+func (arg Argument) helpModel() HelpArgument {
+	helpArg := HelpArgument{
+		Label:     arg.Label,
+		HelpMsg:   arg.HelpMsg,
+		Options:   make([]HelpOption, 0, len(arg.Options)),
+		Arguments: helpArguments(arg.Arguments),
+	}
+	for _, option := range arg.Options {
+		helpArg.Options = append(helpArg.Options, option.helpModel())
+	}
+	return helpArg
+}
+
+// helpModel returns the help for the option as structured data,
+// with the default masked if the option is secret
+func (opt Option) helpModel() HelpOption {
+	helpOpt := HelpOption{
+		Label:    opt.Label,
+		Short:    opt.Short,
+		Long:     opt.Long,
+		HelpMsg:  opt.HelpMsg,
+		Required: opt.Required,
+	}
+	if opt.Variable != nil {
+		helpOpt.Required = helpOpt.Required || opt.Variable.Required
+		helpOpt.Variable = opt.Variable.Label
+		if opt.Variable.Default != "" {
+			helpOpt.Default = opt.mask(opt.Variable.Default)
+		}
+		helpOpt.Choices = append([]string{}, opt.Variable.Choices...)
+		helpOpt.MinLength = opt.Variable.MinLength
+		helpOpt.MaxLength = opt.Variable.MaxLength
+	}
+	return helpOpt
+}
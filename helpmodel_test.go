@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+const helpModelConfig = `
+commands:
+  - label: get
+    aliases: [g]
+    help: Get the things
+    category: Read
+    arguments:
+      - label: all
+        help: Get all the things
+        execFunc: GetAll
+        options:
+          - label: number
+            short: -n
+            long: --number
+            help: The number of things
+            variable:
+              label: count
+              required: true
+              default: "1"
+              choices: ["1", "2"]
+          - label: name
+            long: --name
+            required: true
+            variable:
+              label: name
+              minLength: 2
+              maxLength: 8
+          - label: quiet
+            short: -q
+          - label: token
+            long: --token
+            secret: true
+            variable:
+              label: token
+              default: hunter2
+          - label: password
+            long: --password
+            secret: true
+            variable:
+              label: password
+`
+
+const nestedHelpModelConfig = `
+commands:
+  - label: remote
+    arguments:
+      - label: add
+        arguments:
+          - label: origin
+            execFunc: Origin
+`
+
+func TestHelpModel(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []HelpCommand
+	}{
+		{"options", helpModelConfig, []HelpCommand{{
+			Label:    "get",
+			Aliases:  []string{"g"},
+			HelpMsg:  "Get the things",
+			Category: "Read",
+			Arguments: []HelpArgument{{
+				Label:   "all",
+				HelpMsg: "Get all the things",
+				Options: []HelpOption{
+					{Label: "number", Short: "-n", Long: "--number", HelpMsg: "The number of things", Required: true, Variable: "count", Default: "1", Choices: []string{"1", "2"}},
+					{Label: "name", Long: "--name", Required: true, Variable: "name", Choices: []string{}, MinLength: 2, MaxLength: 8},
+					{Label: "quiet", Short: "-q"},
+					{Label: "token", Long: "--token", Variable: "token", Default: secretMask, Choices: []string{}},
+					{Label: "password", Long: "--password", Variable: "password", Choices: []string{}},
+				},
+				Arguments: []HelpArgument{},
+			}},
+		}}},
+		{"nested", nestedHelpModelConfig, []HelpCommand{{
+			Label:   "remote",
+			Aliases: []string{},
+			Arguments: []HelpArgument{{
+				Label:   "add",
+				Options: []HelpOption{},
+				Arguments: []HelpArgument{{
+					Label:     "origin",
+					Options:   []HelpOption{},
+					Arguments: []HelpArgument{},
+				}},
+			}},
+		}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := loadTestConfig(t, test.config).HelpModel(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("HelpModel() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
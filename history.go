@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// record adds the input to the history of the CLI session. If the
+// history size has been configured, the oldest inputs are discarded
+// once the history is full.
+func (app *App) record(input string) {
+	app.historyMutex.Lock()
+	defer app.historyMutex.Unlock()
+	app.history = append(app.history, input)
+	if size := app.currentConfig().HistorySize; size > 0 && len(app.history) > size {
+		app.discarded += len(app.history) - size
+		app.history = append([]string{}, app.history[len(app.history)-size:]...)
+	}
+}
+
+// History returns a copy of the inputs of the CLI session, oldest first
+func (app *App) History() []string {
+	app.historyMutex.Lock()
+	defer app.historyMutex.Unlock()
+	return append([]string{}, app.history...)
+}
+
+// expandHistory expands a reference to the history into the prior input.
+// With the history expansion prefix "!", the input "!!" expands to the last
+// input and "!n" expands to the nth input of the CLI session, counting any
+// inputs discarded from the history once it was full, so that n is the same
+// number however full the history is. If the input is not a reference to the
+// history, it is returned unchanged with false. An error is returned if the
+// referenced history entry doesn't exist or has been discarded.
+func (app *App) expandHistory(input string) (expanded string, ok bool, err error) {
+	prefix := app.currentConfig().HistoryExpansion
+	if prefix == "" || !strings.HasPrefix(input, prefix) {
+		return input, false, nil
+	}
+	reference := input[len(prefix):]
+	app.historyMutex.Lock()
+	defer app.historyMutex.Unlock()
+
+	// Repeat the last input
+	if reference == prefix {
+		if len(app.history) == 0 {
+			return input, false, fmt.Errorf("history entry \"%s\" not found, history is empty", input)
+		}
+		return app.history[len(app.history)-1], true, nil
+	}
+
+	// Repeat the nth input
+	n, err := strconv.Atoi(reference)
+	if err != nil {
+		return input, false, nil
+	}
+	if n < 1 || n > app.discarded+len(app.history) {
+		return input, false, fmt.Errorf("history entry \"%s\" not found", input)
+	}
+	if n <= app.discarded {
+		return input, false, fmt.Errorf("history entry \"%s\" not found, discarded from the history", input)
+	}
+	return app.history[n-app.discarded-1], true, nil
+}
+
+// suggest returns the remainder of the most recent history entry which
+// starts with the line, so that it can be suggested to complete the line.
+// If the line is empty or there is no matching entry, an empty string
+// is returned instead.
+func suggest(history []string, line string) string {
+	if line == "" {
+		return ""
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if len(history[i]) > len(line) && strings.HasPrefix(history[i], line) {
+			return history[i][len(line):]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHistorySize(t *testing.T) {
+	tests := []struct {
+		size  int
+		input []string
+		want  []string
+	}{
+		{0, []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{2, []string{"a"}, []string{"a"}},
+		{2, []string{"a", "b", "c", "d"}, []string{"c", "d"}},
+	}
+	for _, test := range tests {
+		app := &App{}
+		app.config.Store(&Config{HistorySize: test.size})
+		for _, input := range test.input {
+			app.record(input)
+		}
+		if got := app.History(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("History() of %q with size %d = %q, want %q", test.input, test.size, got, test.want)
+		}
+	}
+}
+
+func TestExpandHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		input   string
+		want    string
+		ok      bool
+		wantErr bool
+	}{
+		{"last", 0, "!!", "get c", true, false},
+		{"first", 0, "!1", "get a", true, false},
+		{"nth", 0, "!3", "get c", true, false},
+		{"past the end", 0, "!4", "", false, true},
+		{"zero", 0, "!0", "", false, true},
+		{"not a reference", 0, "!get", "!get", false, false},
+		{"not expanded", 0, "get a", "get a", false, false},
+		{"last of full history", 2, "!!", "get c", true, false},
+		{"nth of full history", 2, "!3", "get c", true, false},
+		{"discarded", 2, "!1", "", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := &App{}
+			app.config.Store(&Config{HistoryExpansion: "!", HistorySize: test.size})
+			for _, input := range []string{"get a", "get b", "get c"} {
+				app.record(input)
+			}
+			got, ok, err := app.expandHistory(test.input)
+			if (err != nil) != test.wantErr || ok != test.ok || (err == nil && got != test.want) {
+				t.Errorf("expandHistory(%q) = %q, %t, %v, want %q, %t", test.input, got, ok, err, test.want, test.ok)
+			}
+		})
+	}
+	app := &App{}
+	app.config.Store(&Config{HistoryExpansion: "!"})
+	if _, _, err := app.expandHistory("!!"); err == nil {
+		t.Error("expected an error expanding the last input of an empty history")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	history := []string{"get all", "get all -n 5", "set name", "get alpha"}
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"get al", "pha"},
+		{"get all", " -n 5"},
+		{"set", " name"},
+		{"get alpha", ""},
+		{"del", ""},
+		{"", ""},
+		{"GET", ""},
+	}
+	for _, test := range tests {
+		if got := suggest(history, test.line); got != test.want {
+			t.Errorf("suggest(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+	if got := suggest(nil, "get"); got != "" {
+		t.Errorf("suggest() with no history = %q", got)
+	}
+}
+
+func TestHistoryExpansion(t *testing.T) {
+	app, _ := newTestApp(t, "historyExpansion: \"!\"\n"+getConfig, map[string]func(Flags) []byte{"GetAll": echo("all")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"!!", "history entry \"!!\" not found, history is empty\n"},
+		{"get all", "all {quiet: set=false}\n"},
+		{"get all -q", "all {quiet: set=true}\n"},
+		{"!!", "get all -q\nall {quiet: set=true}\n"},
+		{"!1", "get all\nall {quiet: set=false}\n"},
+		{"!2", "get all -q\nall {quiet: set=true}\n"},
+		{"!9", "history entry \"!9\" not found\n"},
+	}
+	for _, test := range tests {
+		if got := string(app.Execute(test.input)); got != test.want {
+			t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+
+	// The references are not recorded, only the inputs they expand to
+	if want := []string{"get all", "get all -q", "get all -q", "get all", "get all -q"}; !reflect.DeepEqual(app.History(), want) {
+		t.Errorf("History() = %q, want %q", app.History(), want)
+	}
+}
+
+func TestHistoryConcurrent(t *testing.T) {
+	app := &App{}
+	app.config.Store(&Config{HistoryExpansion: "!", HistorySize: 10})
+	app.record("first")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			app.record(fmt.Sprintf("input %d", i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		history := app.History()
+		if len(history) == 0 || len(history) > 10 {
+			t.Fatalf("History() = %q, want 1 to 10 inputs", history)
+		}
+		history[0] = "changed"
+		if _, _, err := app.expandHistory("!!"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if history := app.History(); history[0] == "changed" || len(history) != 10 {
+		t.Errorf("History() = %q, want a copy of the last 10 inputs", history)
+	}
+}
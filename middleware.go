@@ -0,0 +1,48 @@
+package cli
+
+// Middleware wraps an executable, returning a new executable which can
+// run code before and after calling next, inspect or replace its output,
+// or skip calling next altogether. This allows logging, authentication,
+// rate-limiting and timing to be composed as layers around commands.
+type Middleware func(next func(Flags) []byte) func(Flags) []byte
+
+// Use appends the middleware to the App. The middleware is applied around
+// the executable of every command, in the order it was registered, such
+// that the first middleware registered is the outermost layer.
+func (app *App) Use(middleware ...Middleware) *App {
+	app.middleware = append(app.middleware, middleware...)
+	return app
+}
+
+// wrap applies the middleware of the App around the executable.
+// The pre and post functions of the config are applied first, so
+// that they are the innermost layer around the executable.
+func (app *App) wrap(executable func(Flags) []byte) func(Flags) []byte {
+	executable = app.hook(executable)
+	for i := len(app.middleware) - 1; i >= 0; i-- {
+		executable = app.middleware[i](executable)
+	}
+	return executable
+}
+
+// hook applies the pre and post functions of the config around the executable.
+// If the pre function returns any output, the executable and the post function
+// are not run.
+func (app *App) hook(executable func(Flags) []byte) func(Flags) []byte {
+	pre, post := app.currentConfig().pre, app.currentConfig().post
+	if pre == nil && post == nil {
+		return executable
+	}
+	return func(flags Flags) []byte {
+		if pre != nil {
+			if output := pre(flags); len(output) > 0 {
+				return output
+			}
+		}
+		output := executable(flags)
+		if post != nil {
+			output = append(output, post(flags)...)
+		}
+		return output
+	}
+}
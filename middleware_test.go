@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const getConfig = `
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: quiet
+            short: -q
+`
+
+func TestInputMiddleware(t *testing.T) {
+	stripSlash := func(input string) (string, bool) { return strings.TrimPrefix(input, "/"), true }
+	expandAlias := func(input string) (string, bool) { return strings.Replace(input, "ga", "get all", 1), true }
+	veto := func(input string) (string, bool) { return input, !strings.Contains(input, "-q") }
+	tests := []struct {
+		name       string
+		middleware []func(string) (string, bool)
+		input      string
+		want       string
+		wantErr    bool
+	}{
+		{"none", nil, "get all", "all {quiet: set=false}\n", false},
+		{"rewrite", []func(string) (string, bool){stripSlash}, "/get all", "all {quiet: set=false}\n", false},
+		{"chained in order", []func(string) (string, bool){stripSlash, expandAlias}, "/ga", "all {quiet: set=false}\n", false},
+		{"veto", []func(string) (string, bool){veto}, "get all -q", "", false},
+		{"not vetoed", []func(string) (string, bool){veto}, "get all", "all {quiet: set=false}\n", false},
+		{"without rewrite", nil, "/get all", "unable to find command \"/get\", did you mean \"get\"?\n", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, _ := newTestApp(t, getConfig, map[string]func(Flags) []byte{"GetAll": echo("all")}, "")
+			app.currentConfig().InputMiddleware = test.middleware
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+
+			// Check and Parse prepare the input exactly like Execute
+			if err := app.Check(test.input); (err != nil) != test.wantErr {
+				t.Errorf("Check(%q) = %v, want error %t", test.input, err, test.wantErr)
+			}
+			_, _, flags, err := app.Parse(test.input)
+			switch {
+			case test.want == "" && err != errVetoed:
+				t.Errorf("Parse(%q) = %v, want the input to be vetoed", test.input, err)
+			case test.want != "" && (err != nil) != test.wantErr:
+				t.Errorf("Parse(%q) = %v, want error %t", test.input, err, test.wantErr)
+			case err == nil && "all "+flags.String()+"\n" != test.want:
+				t.Errorf("Parse(%q) flags = %s, want %q", test.input, flags, test.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var order []string
+	layer := func(name string) Middleware {
+		return func(next func(Flags) []byte) func(Flags) []byte {
+			return func(flags Flags) []byte {
+				order = append(order, name+" before")
+				output := next(flags)
+				order = append(order, name+" saw "+strings.TrimSpace(string(output)))
+				return []byte("[" + name + " " + string(output) + "]")
+			}
+		}
+	}
+	skip := func(next func(Flags) []byte) func(Flags) []byte {
+		return func(Flags) []byte { return []byte("denied") }
+	}
+	tests := []struct {
+		name       string
+		middleware []Middleware
+		want       string
+		order      []string
+	}{
+		{"none", nil, "all\n", []string{"all"}},
+		{"two", []Middleware{layer("outer"), layer("inner")}, "[outer [inner all\n]]", []string{"outer before", "inner before", "all", "inner saw all", "outer saw [inner all\n]"}},
+		{"skip", []Middleware{layer("outer"), skip}, "[outer denied]", []string{"outer before", "outer saw denied"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order = nil
+			app, _ := newTestApp(t, getConfig, map[string]func(Flags) []byte{"GetAll": func(Flags) []byte {
+				order = append(order, "all")
+				return []byte("all\n")
+			}}, "")
+			app.Use(test.middleware...)
+			if got := string(app.Execute("get all")); got != test.want {
+				t.Errorf("Execute() = %q, want %q", got, test.want)
+			}
+			if !reflect.DeepEqual(order, test.order) {
+				t.Errorf("ran %q, want %q", order, test.order)
+			}
+		})
+	}
+}
+
+func TestPrePostFuncs(t *testing.T) {
+	var order []string
+	record := func(name string, out string) func(Flags) []byte {
+		return func(flags Flags) []byte {
+			order = append(order, name+" "+flags.String())
+			return []byte(out)
+		}
+	}
+	tests := []struct {
+		name  string
+		hooks string
+		pre   string
+		input string
+		want  string
+		order []string
+	}{
+		{"pre and post", "preFunc: Pre\npostFunc: Post\n", "", "get all -q", "all\npost\n", []string{"pre {quiet: set=true}", "all {quiet: set=true}", "post {quiet: set=true}"}},
+		{"pre only", "preFunc: Pre\n", "", "get all", "all\n", []string{"pre {quiet: set=false}", "all {quiet: set=false}"}},
+		{"post only", "postFunc: Post\n", "", "get all", "all\npost\n", []string{"all {quiet: set=false}", "post {quiet: set=false}"}},
+		{"pre short circuits", "preFunc: Pre\npostFunc: Post\n", "denied\n", "get all", "denied\n", []string{"pre {quiet: set=false}"}},
+		{"not run on errors", "preFunc: Pre\npostFunc: Post\n", "", "get none", "invalid use of the \"get\" command, no valid argument provided\n", nil},
+		{"not run for help", "preFunc: Pre\npostFunc: Post\n", "", "get help", "\nUsage: get\n\nget all\n\tall \n\nget all -q\n\t-q  \n\n", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order = nil
+			funcs := map[string]func(Flags) []byte{"Pre": record("pre", test.pre), "Post": record("post", "post\n"), "GetAll": record("all", "all\n")}
+			app, _ := newTestApp(t, test.hooks+getConfig, funcs, "")
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+			if !reflect.DeepEqual(order, test.order) {
+				t.Errorf("ran %q, want %q", order, test.order)
+			}
+		})
+	}
+}
+
+func TestPrePostFuncsInsideMiddleware(t *testing.T) {
+	app, _ := newTestApp(t, "preFunc: Pre\npostFunc: Post\n"+getConfig, map[string]func(Flags) []byte{"Pre": output(""), "Post": output("post\n"), "GetAll": output("all\n")}, "")
+	app.Use(func(next func(Flags) []byte) func(Flags) []byte {
+		return func(flags Flags) []byte { return []byte("[" + string(next(flags)) + "]") }
+	})
+	if got, want := string(app.Execute("get all")), "[all\npost\n]"; got != want {
+		t.Errorf("Execute() = %q, want %q", got, want)
+	}
+}
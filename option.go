@@ -1,5 +1,11 @@
 package cli
 
+import (
+	"errors"
+	"io/fs"
+	"strings"
+)
+
 // Option is a character, set of consecutive characters,
 // or a word that follows the command and any arguments.
 // Options are preceded by an dash (–).
@@ -7,20 +13,95 @@ type Option struct {
 
 	// Name of the variable that will be used as a key for
 	// a flag mapping when used in a action function.
-	Label string `yaml:"label"`
+	Label string `yaml:"label" json:"label"`
 
 	// Short name, single dash (–) followed by a signle
 	// character.
-	Short string `yaml:"short"`
+	Short string `yaml:"short,omitempty" json:"short,omitempty"`
 
 	// Long name, double dash (--) followed by a
 	// descriptive name.
-	Long string `yaml:"long"`
+	Long string `yaml:"long,omitempty" json:"long,omitempty"`
 
 	// (optional) if this option requires a variable,
 	// it should be defined here.
-	Variable *Variable `yaml:"variable"`
+	Variable *Variable `yaml:"variable,omitempty" json:"variable,omitempty"`
 
 	// (optional) help message for this option.
-	HelpMsg string `yaml:"help"`
+	HelpMsg string `yaml:"help,omitempty" json:"help,omitempty"`
+
+	// (optional) if true, this option must be present
+	// on every invocation of the argument.
+	Required bool `yaml:"required,omitempty" json:"required,omitempty"`
+
+	// (optional) labels of the options which are reset to
+	// unset when this option is encountered in the input.
+	// Options are parsed in order, so any of these options
+	// which appear later in the input will still be set.
+	Clears []string `yaml:"clears,omitempty" json:"clears,omitempty"`
+
+	// (optional) labels of the options which must also be set
+	// whenever this option is set, e.g. the option for (--password)
+	// for the option for (--user).
+	Requires []string `yaml:"requires,omitempty" json:"requires,omitempty"`
+
+	// (optional) if true, when this option is omitted from the
+	// input, it takes the value it was last set to in the session.
+	// The values are forgotten when the reset command is input.
+	Sticky bool `yaml:"sticky,omitempty" json:"sticky,omitempty"`
+
+	// (optional) if true, this option can be given more than
+	// once in the input, with the value of each occurrence
+	// collected in order, available from Flags.GetVars.
+	Repeatable bool `yaml:"repeatable,omitempty" json:"repeatable,omitempty"`
+
+	// (optional) if true, the value of this option is sensitive,
+	// e.g. a password or a token, and is masked wherever it would
+	// be shown, namely in errors, in the records written to the
+	// audit writer and in the dumps of the flags.
+	Secret bool `yaml:"secret,omitempty" json:"secret,omitempty"`
+
+	// (optional) if true, the variable of this option is a key
+	// value pair, e.g. NAME=VALUE, available from Flags.GetKeyValue,
+	// or as a map from Flags.GetKeyValues for a repeatable option.
+	// The pair of the long option may also be the next token, e.g.
+	// (--env NAME=VALUE).
+	KeyValue bool `yaml:"keyValue,omitempty" json:"keyValue,omitempty"`
+
+	// Whether the option was inherited from the options of the command
+	inherited bool
+}
+
+// mask returns the secret mask in place of the value if the option is secret,
+// otherwise the value is returned unchanged.
+func (opt Option) mask(value string) string {
+	if opt.Secret {
+		return secretMask
+	}
+	return value
+}
+
+// redact returns the error with the quoted value, or the path of a file
+// error, replaced by the secret mask if the option is secret, otherwise
+// the error is returned unchanged.
+func (opt Option) redact(err error, value string) error {
+	if !opt.Secret || value == "" {
+		return err
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: secretMask, Err: pathErr.Err}
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), "\""+value+"\"", "\""+secretMask+"\""))
+}
+
+// splitKeyValue splits the value into the key before the first equals
+// sign (=) and the value after it. It also returns whether the value is
+// a key value pair, which is only the case if the key is not empty.
+func splitKeyValue(s string) (key, value string, ok bool) {
+	key, value, found := strings.Cut(s, "=")
+	if !found || key == "" {
+		return "", "", false
+	}
+	return key, value, true
 }
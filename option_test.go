@@ -0,0 +1,514 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const secretConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: login
+    arguments:
+      - label: ""
+        execFunc: Login
+        options:
+          - label: token
+            short: -t
+            long: --token
+            secret: true
+            repeatable: true
+            variable:
+              label: token
+              choices: [hunter2, sekrit, other]
+          - label: pin
+            long: --pin
+            secret: true
+            variable:
+              label: pin
+              minLength: 4
+          - label: user
+            long: --user
+            variable:
+              label: user
+`
+
+func TestSecretMaskedInErrors(t *testing.T) {
+	app, _ := newTestApp(t, secretConfig, map[string]func(Flags) []byte{"Login": output("")}, "")
+	tests := []struct {
+		name   string
+		input  string
+		secret string
+	}{
+		{"invalid choice", "login --token=letmein", "letmein"},
+		{"too short", "login --pin=123", "123"},
+		{"value after long option", "login --token -hunter2", "hunter2"},
+		{"text after long option", "login --token hunter2", "hunter2"},
+		{"value after short option", "login -t -sekrit", "sekrit"},
+		{"combined with short option", "login -thunter2", "hunter2"},
+		{"combined after unknown option", "login -xthunter2", "hunter2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, _, err := app.Parse(test.input)
+			if err == nil {
+				t.Fatalf("expected an error for %q", test.input)
+			}
+			if strings.Contains(err.Error(), test.secret) {
+				t.Errorf("error %q contains the secret %q", err, test.secret)
+			}
+			if !strings.Contains(err.Error(), secretMask) {
+				t.Errorf("error %q does not contain the mask", err)
+			}
+		})
+	}
+}
+
+func TestSecretMaskedInDumps(t *testing.T) {
+	app, _ := newTestApp(t, secretConfig, map[string]func(Flags) []byte{"Login": output("")}, "")
+	_, _, flags, err := app.Parse("login --token=hunter2 --token=sekrit --user=bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, dump := range map[string]string{"String": flags.String(), "debugString": flags.debugString()} {
+		for _, secret := range []string{"hunter2", "sekrit"} {
+			if strings.Contains(dump, secret) {
+				t.Errorf("%s dump %q contains the secret %q", name, dump, secret)
+			}
+		}
+		if !strings.Contains(dump, "bob") {
+			t.Errorf("%s dump %q does not contain the value of a non-secret option", name, dump)
+		}
+	}
+}
+
+func TestSecretMaskedInAudit(t *testing.T) {
+	app, _ := newTestApp(t, secretConfig, map[string]func(Flags) []byte{"Login": output("")}, "")
+	audit := new(bytes.Buffer)
+	app.currentConfig().AuditWriter = audit
+	app.Execute("login --token=hunter2 --user=bob")
+	if strings.Contains(audit.String(), "hunter2") || !strings.Contains(audit.String(), secretMask) {
+		t.Errorf("audit record %q does not mask the secret", audit)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		option Option
+		err    string
+		value  string
+		want   string
+	}{
+		{"secret", Option{Secret: true}, `invalid size "a"`, "a", `invalid size "***"`},
+		{"not secret", Option{}, `invalid size "a"`, "a", `invalid size "a"`},
+		{"empty value", Option{Secret: true}, `invalid size ""`, "", `invalid size ""`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.option.redact(errorString(test.err), test.value).Error(); got != test.want {
+				t.Errorf("redact() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// errorString is an error with the message
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+const clearsConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: build
+    arguments:
+      - label: ""
+        execFunc: Build
+        options:
+          - label: tag
+            short: -t
+            long: --tag
+            repeatable: true
+            variable:
+              label: tag
+          - label: verbose
+            short: -v
+          - label: reset
+            long: --reset
+            clears: [tag, verbose]
+`
+
+func TestClears(t *testing.T) {
+	app, _ := newTestApp(t, clearsConfig, map[string]func(Flags) []byte{"Build": output("")}, "")
+	tests := []struct {
+		input   string
+		tags    []string
+		verbose bool
+	}{
+		{"build -t a -t b", []string{"a", "b"}, false},
+		{"build -t a -t b --reset", nil, false},
+		{"build -t a -v --reset -t c", []string{"c"}, false},
+		{"build --reset -v -t a", []string{"a"}, true},
+		{"build -vv --reset", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tags := flags.GetVars("tag"); !reflect.DeepEqual(tags, test.tags) && (len(tags) != 0 || len(test.tags) != 0) {
+				t.Errorf("GetVars(tag) = %q, want %q", tags, test.tags)
+			}
+			if flags.IsSet("verbose") != test.verbose {
+				t.Errorf("IsSet(verbose) = %t, want %t", flags.IsSet("verbose"), test.verbose)
+			}
+			if !test.verbose && flags.Count("verbose") != 0 {
+				t.Errorf("Count(verbose) = %d after the reset", flags.Count("verbose"))
+			}
+		})
+	}
+}
+
+const combinedConfig = `
+commands:
+  - label: ls
+    arguments:
+      - label: ""
+        execFunc: Ls
+        options:
+          - label: all
+            short: -a
+          - label: long
+            short: -l
+          - label: human
+            short: -h
+          - label: sort
+            short: -s
+            variable:
+              label: sort
+`
+
+func TestCombinedShortOptions(t *testing.T) {
+	app, _ := newTestApp(t, combinedConfig, map[string]func(Flags) []byte{"Ls": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"ls -alh", `{all: set=true, human: set=true, long: set=true, sort: set=false value=""}`},
+		{"ls -la", `{all: set=true, human: set=false, long: set=true, sort: set=false value=""}`},
+		{"ls -al -s name", `{all: set=true, human: set=false, long: set=true, sort: set=true value="name"}`},
+		{"ls -alx", `unknown option "-x" in "-alx"`},
+		{"ls -als name", `option "-s" in "-als" requires a variable and cannot be combined with other options`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := parseTest(app, test.input); got != test.want {
+				t.Errorf("Parse(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const requiredConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: copy
+    arguments:
+      - label: files
+        execFunc: Files
+        options:
+          - label: source
+            long: --source
+            required: true
+            variable:
+              label: source
+          - label: dest
+            long: --dest
+            required: true
+            variable:
+              label: dest
+          - label: force
+            short: -f
+`
+
+func TestRequiredOptions(t *testing.T) {
+	executed := 0
+	app, _ := newTestApp(t, requiredConfig, map[string]func(Flags) []byte{"Files": func(Flags) []byte {
+		executed++
+		return []byte("copied\n")
+	}}, "")
+	tests := []struct {
+		input    string
+		want     string
+		executed int
+	}{
+		{"copy files --source=a --dest=b", "copied\n", 1},
+		{"copy files --dest=b --source=a -f", "copied\n", 1},
+		{"copy files --source=a", "missing required options \"dest\"\n", 0},
+		{"copy files -f", "missing required options \"source\", \"dest\"\n", 0},
+		{"copy files", "missing required options \"source\", \"dest\"\n", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			executed = 0
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+			if executed != test.executed {
+				t.Errorf("Execute(%q) ran the executable %d times, want %d", test.input, executed, test.executed)
+			}
+		})
+	}
+}
+
+const repeatableConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: add
+    arguments:
+      - label: item
+        execFunc: Item
+        options:
+          - label: tag
+            short: -t
+            long: --tag
+            repeatable: true
+            variable:
+              label: tag
+          - label: name
+            short: -n
+            variable:
+              label: name
+`
+
+func TestRepeatable(t *testing.T) {
+	tests := []struct {
+		input     string
+		duplicate bool
+		tags      []string
+		name      string
+		err       string
+	}{
+		{"add item --tag=x --tag=y --tag=z", false, []string{"x", "y", "z"}, "", ""},
+		{"add item --tag=x -t y --tag=z", false, []string{"x", "y", "z"}, "", ""},
+		{"add item -t x", false, []string{"x"}, "", ""},
+		{"add item", false, nil, "", ""},
+		{"add item -n a -n b", false, nil, "b", ""},
+		{"add item -t x -t y -t z", true, []string{"x", "y", "z"}, "", ""},
+		{"add item -n a -n b", true, nil, "", `option "name" provided more than once`},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s duplicate=%t", test.input, test.duplicate), func(t *testing.T) {
+			app, _ := newTestApp(t, repeatableConfig, map[string]func(Flags) []byte{"Item": output("")}, "")
+			app.currentConfig().ErrorOnDuplicate = test.duplicate
+			_, _, flags, err := app.Parse(test.input)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Parse(%q) = %v, want %q", test.input, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := flags.GetVars("tag"); !reflect.DeepEqual(got, test.tags) {
+				t.Errorf("GetVars(tag) = %q, want %q", got, test.tags)
+			}
+			if got, _ := flags.GetVar("name"); got != test.name {
+				t.Errorf("GetVar(name) = %q, want %q", got, test.name)
+			}
+		})
+	}
+}
+
+const unknownOptionsConfig = `
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: quiet
+            short: -q
+            long: --quiet
+`
+
+func TestUnknownOptions(t *testing.T) {
+	tests := []struct {
+		input  string
+		strict bool
+		want   string
+	}{
+		{"get all -x", true, "unknown option \"-x\", did you mean \"-q\"?\n"},
+		{"get all --verbose", true, "unknown option \"--verbose\"\n"},
+		{"get all --quite", true, "unknown option \"--quite\", did you mean \"--quiet\"?\n"},
+		{"get all -q -x", true, "unknown option \"-x\", did you mean \"-q\"?\n"},
+		{"get all -q", true, "all {quiet: set=true}\n"},
+		{"get all -x", false, "all {quiet: set=false}\n"},
+		{"get all --verbose", false, "all {quiet: set=false}\n"},
+		{"get all --quite -q", false, "all {quiet: set=true}\n"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s strict=%t", test.input, test.strict), func(t *testing.T) {
+			config := unknownOptionsConfig
+			if test.strict {
+				config = "strictOptions: true\n" + config
+			}
+			app, _ := newTestApp(t, config, map[string]func(Flags) []byte{"GetAll": echo("all")}, "")
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const exclusiveConfig = `
+commands:
+  - label: set
+    arguments:
+      - label: power
+        execFunc: Power
+        exclusiveGroups: [[on, off, toggle], [loud, quiet]]
+        options:
+          - label: on
+            long: --on
+          - label: off
+            long: --off
+          - label: toggle
+            short: -t
+          - label: loud
+            short: -l
+          - label: quiet
+            short: -q
+`
+
+func TestExclusiveGroups(t *testing.T) {
+	app, _ := newTestApp(t, exclusiveConfig, map[string]func(Flags) []byte{"Power": output("power\n")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"set power", "power\n"},
+		{"set power --on", "power\n"},
+		{"set power --off -q", "power\n"},
+		{"set power --on --on", "power\n"},
+		{"set power --on --off", "options \"on\", \"off\" cannot be used together\n"},
+		{"set power --off -t --on", "options \"on\", \"off\", \"toggle\" cannot be used together\n"},
+		{"set power -lq", "options \"loud\", \"quiet\" cannot be used together\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const requiresConfig = `
+commands:
+  - label: login
+    arguments:
+      - label: server
+        execFunc: Server
+        options:
+          - label: user
+            long: --user
+            requires: [password, host]
+            variable:
+              label: name
+          - label: password
+            long: --password
+            requires: [user]
+            variable:
+              label: password
+          - label: host
+            short: -h
+`
+
+func TestRequires(t *testing.T) {
+	app, _ := newTestApp(t, requiresConfig, map[string]func(Flags) []byte{"Server": output("server\n")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"login server", "server\n"},
+		{"login server -h", "server\n"},
+		{"login server --user=me --password=pw -h", "server\n"},
+		{"login server --user=me --password=pw", "option \"user\" missing required options \"host\"\n"},
+		{"login server --user=me", "option \"user\" missing required options \"password\", \"host\"\n"},
+		{"login server --password=pw -h", "option \"password\" missing required options \"user\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+const negationConfig = `
+strictOptions: true
+commands:
+  - label: show
+    arguments:
+      - label: log
+        execFunc: Log
+        options:
+          - label: color
+            long: --color
+          - label: pager
+            short: -p
+          - label: format
+            long: --format
+            variable:
+              label: format
+`
+
+func TestNegation(t *testing.T) {
+	app, _ := newTestApp(t, negationConfig, map[string]func(Flags) []byte{"Log": output("")}, "")
+	tests := []struct {
+		input string
+		value bool
+		set   bool
+		err   string
+	}{
+		{"show log --color", true, true, ""},
+		{"show log --no-color", false, false, ""},
+		{"show log", false, false, `option "color" has not been set`},
+		{"show log --no-color --color", true, true, ""},
+		{"show log --color --no-color", false, false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := flags.GetBool("color")
+			if value != test.value || fmt.Sprint(err) != test.err && !(err == nil && test.err == "") {
+				t.Errorf("GetBool(color) = %t, %v, want %t, %q", value, err, test.value, test.err)
+			}
+			if flags.IsSet("color") != test.set {
+				t.Errorf("IsSet(color) = %t, want %t", flags.IsSet("color"), test.set)
+			}
+		})
+	}
+
+	// Only long options without a variable can be negated
+	for input, want := range map[string]string{
+		"show log --no-format": `unknown option "--no-format"`,
+		"show log --no-p":      `unknown option "--no-p"`,
+		"show log --no-colour": `unknown option "--no-colour"`,
+	} {
+		if _, _, _, err := app.Parse(input); err == nil || err.Error() != want {
+			t.Errorf("Parse(%q) = %v, want %q", input, err, want)
+		}
+	}
+}
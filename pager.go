@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// pagerPrompt is written after each page of output until a key is pressed
+const pagerPrompt = "--More--"
+
+// writePaged writes the output, paging it if the pager is enabled and the
+// output has more lines than fit in the terminal. Each page fills the
+// terminal except for its last line, where the pager prompt is written.
+// Then space shows the next page, enter shows the next line, and q or
+// ctl-C discards the rest of the output.
+func (app *App) writePaged(output []byte) error {
+	height := app.pageHeight()
+	lines := bytes.SplitAfter(output, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if height <= 0 || len(lines) < height {
+		return app.write(output)
+	}
+
+	next := height - 1
+	for {
+		count := minInt(next, len(lines))
+		if err := app.write(bytes.Join(lines[:count], nil)); err != nil {
+			return err
+		}
+		lines = lines[count:]
+		if len(lines) == 0 {
+			return nil
+		}
+
+		// Wait for a key to decide how much more of the output to show
+		key, err := app.readPagerKey()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case ' ':
+			next = height - 1
+		case keyEnter, keyNewline:
+			next = 1
+		case 'q', 'Q', keyCtrlC:
+			return nil
+		default:
+			next = 0
+		}
+	}
+}
+
+// pageHeight returns the number of lines in a page of output, which is the
+// height of the terminal. If the pager is disabled, or either the input or
+// the output of the CLI is not a terminal, 0 is returned instead.
+func (app *App) pageHeight() int {
+	if !app.currentConfig().Pager || !isTerminal(app.in) || !isTerminal(app.out) {
+		return 0
+	}
+	_, height, err := term.GetSize(int(app.out.(*os.File).Fd()))
+	if err != nil || height < 2 {
+		return 0
+	}
+	return height
+}
+
+// readPagerKey writes the pager prompt and reads a single key from the
+// terminal in raw mode, then clears the prompt.
+func (app *App) readPagerKey() (rune, error) {
+	if err := app.write([]byte(colorize(pagerPrompt, ansiGrey, app.color()))); err != nil {
+		return 0, err
+	}
+	fd := int(app.in.(*os.File).Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return 0, err
+	}
+	key, _, err := app.reader.ReadRune()
+	term.Restore(fd, state)
+	if err != nil {
+		return 0, err
+	}
+	return key, app.write([]byte("\r\x1b[K"))
+}
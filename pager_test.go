@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPagerNotTerminal(t *testing.T) {
+	long := strings.Repeat("line\n", 1000)
+	tests := []struct {
+		name   string
+		pager  bool
+		file   bool
+		output string
+	}{
+		{"disabled", false, false, long},
+		{"buffer", true, false, long},
+		{"file", true, true, long},
+		{"without a trailing line ending", true, false, strings.TrimSuffix(long, "\n")},
+		{"short", true, false, "line\n"},
+		{"empty", true, false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out io.Writer = new(bytes.Buffer)
+			var file *os.File
+			if test.file {
+				var err error
+				if file, err = os.CreateTemp(t.TempDir(), "out"); err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+				out = file
+			}
+			app := NewWithIO(loadTestConfig(t, fmt.Sprintf("pager: %t\n", test.pager)+getConfig), strings.NewReader(""), out)
+			if height := app.pageHeight(); height != 0 {
+				t.Errorf("pageHeight() = %d, want paging to be skipped", height)
+			}
+			if err := app.writePaged([]byte(test.output)); err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if file != nil {
+				b, err := os.ReadFile(file.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(b)
+			} else {
+				got = out.(*bytes.Buffer).String()
+			}
+			if got != test.output {
+				t.Errorf("writePaged() wrote %d bytes, want all %d bytes of the output", len(got), len(test.output))
+			}
+		})
+	}
+}
+
+func TestPagerRun(t *testing.T) {
+	long := strings.Repeat("line\n", 1000)
+	app, out := newTestApp(t, "pager: true\n"+getConfig, map[string]func(Flags) []byte{"GetAll": output(long)}, "get all\nget all\n")
+	app.Run()
+	if want := long + long; out.String() != want {
+		t.Errorf("Run() wrote %d bytes, want all %d bytes of the output", out.Len(), len(want))
+	}
+	if strings.Contains(out.String(), pagerPrompt) {
+		t.Error("expected the output not to be paged")
+	}
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The variable type for filesystem paths
+const typePath = "path"
+
+// expandPath expands a leading tilde (~) into the home directory of the
+// user and cleans the path.
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// checkPath returns an error if the path doesn't exist
+func checkPath(path string) error {
+	if _, err := os.Stat(expandPath(path)); err != nil {
+		return fmt.Errorf("path \"%s\" does not exist", path)
+	}
+	return nil
+}
+
+// GetPath returns the variable set for the option with the given label as a
+// filesystem path, with a leading tilde (~) expanded to the home directory
+// and the path cleaned. If the option has not been set, doesn't have a
+// variable or doesn't exist in Flags, ("", false) will be returned instead.
+func (flags Flags) GetPath(label string) (path string, exists bool) {
+	variable, ok := flags.GetVar(label)
+	if !ok {
+		return "", false
+	}
+	return expandPath(variable), true
+}
+
+// GetExistingPath returns the variable set for the option with the given label
+// as a filesystem path, in the same way as GetPath. An error is returned if the
+// variable is missing or the path doesn't exist.
+func (flags Flags) GetExistingPath(label string) (string, error) {
+	path, ok := flags.GetPath(label)
+	if !ok {
+		return "", fmt.Errorf("missing variable for option \"%s\"", label)
+	}
+	if err := checkPath(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
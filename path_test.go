@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const pathConfig = `
+commands:
+  - label: open
+    arguments:
+      - label: file
+        execFunc: File
+        options:
+          - label: path
+            short: -p
+            variable:
+              label: path
+              type: path
+          - label: existing
+            short: -e
+            variable:
+              label: existing
+              type: path
+              mustExist: true
+`
+
+func TestGetPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	app, _ := newTestApp(t, pathConfig, map[string]func(Flags) []byte{"File": output("")}, "")
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"open file -p a/b/../c", "a/c", true},
+		{"open file -p ./a//b/", "a/b", true},
+		{"open file -p /tmp/../etc", "/etc", true},
+		{"open file -p ~", home, true},
+		{"open file -p ~/notes.txt", filepath.Join(home, "notes.txt"), true},
+		{"open file -p ~other/notes.txt", "~other/notes.txt", true},
+		{"open file", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := flags.GetPath("path"); got != test.want || ok != test.ok {
+				t.Errorf("GetPath(path) = %q, %t, want %q, %t", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestExistingPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+	app, _ := newTestApp(t, pathConfig, map[string]func(Flags) []byte{"File": output("")}, "")
+	tests := []struct {
+		name     string
+		input    string
+		label    string
+		parseErr string
+		getErr   string
+	}{
+		{"exists", "open file -e " + file, "existing", "", ""},
+		{"uncleaned", "open file -e " + dir + "/../" + filepath.Base(dir) + "/notes.txt", "existing", "", ""},
+		{"missing", "open file -e " + missing, "existing", fmt.Sprintf(`option "existing", path "%s" does not exist`, missing), ""},
+		{"missing without must exist", "open file -p " + missing + " -e " + file, "path", "", fmt.Sprintf(`path "%s" does not exist`, missing)},
+		{"unset", "open file -e " + file, "path", "", `missing variable for option "path"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if test.parseErr != "" {
+				if fmt.Sprint(err) != test.parseErr {
+					t.Errorf("Parse(%q) = %v, want %q", test.input, err, test.parseErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = flags.GetExistingPath(test.label)
+			if (err == nil && test.getErr != "") || (err != nil && err.Error() != test.getErr) {
+				t.Errorf("GetExistingPath(%s) = %v, want %q", test.label, err, test.getErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package cli
+
+import "strings"
+
+// Session allows an executable to interact with the user of the CLI
+// while it is running, e.g. to confirm a destructive command. Methods
+// of the type func(Flags, *Session) []byte receive the Session of the
+// CLI, which prompts through the output of the App and reads the
+// answers from its input.
+type Session struct {
+	app *App
+}
+
+// Prompt writes the message to the CLI and returns the line input in
+// response, with any surrounding delimiters removed. If the session
+// has no input, or the input has ended, an empty string is returned.
+func (session *Session) Prompt(msg string) string {
+	if session == nil || session.app == nil || session.app.in == nil {
+		return ""
+	}
+	if err := session.app.write([]byte(msg)); err != nil {
+		return ""
+	}
+	line, _ := session.app.reader.ReadString('\n')
+	return strings.Trim(line, session.app.currentConfig().lineDelimiters())
+}
+
+// Confirm writes the message to the CLI followed by (y/N), and returns
+// whether the user answered yes, either "y" or "yes" ignoring case. Any
+// other answer, including no answer, is taken as no.
+func (session *Session) Confirm(msg string) bool {
+	switch strings.ToLower(session.Prompt(msg + " (y/N) ")) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
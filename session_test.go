@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+const sessionConfig = `
+prompt: "$ "
+commands:
+  - label: delete
+    arguments:
+      - label: all
+        execFunc: Delete
+  - label: rename
+    arguments:
+      - label: file
+        execFunc: Rename
+`
+
+// sessionProgram is a program with methods which prompt the user
+type sessionProgram struct{}
+
+func (sessionProgram) Init(Flags) []byte { return nil }
+func (sessionProgram) Exit(Flags) []byte { return []byte("bye\n") }
+
+func (sessionProgram) Delete(_ Flags, session *Session) []byte {
+	if !session.Confirm("Are you sure?") {
+		return []byte("cancelled\n")
+	}
+	return []byte("deleted\n")
+}
+
+func (sessionProgram) Rename(_ Flags, session *Session) []byte {
+	return []byte("renamed to \"" + session.Prompt("New name: ") + "\"\n")
+}
+
+func TestSession(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"confirmed", "delete all\ny\n", "$ Are you sure? (y/N) deleted\n$ bye\n"},
+		{"confirmed in full", "delete all\nYes\n", "$ Are you sure? (y/N) deleted\n$ bye\n"},
+		{"confirmed with whitespace", "delete all\n  y \n", "$ Are you sure? (y/N) deleted\n$ bye\n"},
+		{"denied", "delete all\nn\n", "$ Are you sure? (y/N) cancelled\n$ bye\n"},
+		{"no answer", "delete all\n\n", "$ Are you sure? (y/N) cancelled\n$ bye\n"},
+		{"other answer", "delete all\nsure\n", "$ Are you sure? (y/N) cancelled\n$ bye\n"},
+		{"end of input", "delete all\n", "$ Are you sure? (y/N) cancelled\n$ bye\n"},
+		{"answer not run as a command", "delete all\nexit\ndelete all\ny\n", "$ Are you sure? (y/N) cancelled\n$ Are you sure? (y/N) deleted\n$ bye\n"},
+		{"prompt", "rename file\nnotes.txt\n", "$ New name: renamed to \"notes.txt\"\n$ bye\n"},
+		{"prompt end of input", "rename file\n", "$ New name: renamed to \"\"\n$ bye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := new(strings.Builder)
+			app, err := NewWithIO(loadTestConfig(t, sessionConfig), strings.NewReader(test.input), out).Using(sessionProgram{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Run()
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestSessionDelimiters(t *testing.T) {
+	tests := []struct {
+		name       string
+		delimiters string
+		input      string
+		want       string
+	}{
+		{"default delimiters", "", "rename file\n\t notes.txt \n", "$ New name: renamed to \"notes.txt\"\n$ bye\n"},
+		{"custom delimiters", " ,", "rename file\n,notes.txt, \n", "$ New name: renamed to \"notes.txt\"\n$ bye\n"},
+		{"tabs not delimiters", " ", "rename file\n\tnotes.txt\r\n", "$ New name: renamed to \"\tnotes.txt\"\n$ bye\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := new(strings.Builder)
+			config := loadTestConfig(t, sessionConfig)
+			config.Delimiters = test.delimiters
+			app, err := NewWithIO(config, strings.NewReader(test.input), out).Using(sessionProgram{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Run()
+			if out.String() != test.want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestSessionWithoutApp(t *testing.T) {
+	var flags Flags
+	if got := flags.Session().Prompt("Name: "); got != "" {
+		t.Errorf("Prompt() = %q, want no answer", got)
+	}
+	if flags.Session().Confirm("Are you sure?") {
+		t.Error("Confirm() = true, want no answer to be taken as no")
+	}
+	var session *Session
+	if got := session.Prompt("Name: "); got != "" {
+		t.Errorf("Prompt() = %q on a nil session, want no answer", got)
+	}
+}
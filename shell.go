@@ -0,0 +1,258 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// completionEntry is the completion candidates for the word following
+// the words of an invocation of the CLI, namely the labelled arguments
+// and the options.
+type completionEntry struct {
+	words     []string
+	arguments []Argument
+	options   []Option
+}
+
+// candidates returns the argument labels and option names of the entry
+func (entry completionEntry) candidates() []string {
+	candidates := make([]string, 0)
+	for _, argument := range entry.arguments {
+		candidates = append(candidates, argument.Label)
+	}
+	return append(candidates, optionNames(entry.options)...)
+}
+
+// invalidFuncCharacters matches the characters which can't be used in a shell function name
+var invalidFuncCharacters = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// GenerateBashCompletion returns a bash completion script for the CLI when it is
+// run as the program progName, e.g. by sourcing the script from ~/.bashrc. The
+// first word is completed from the commands, and the words following a command
+// are completed from its arguments, any child arguments and the option longs.
+func (config *Config) GenerateBashCompletion(progName string) string {
+	funcName := "_" + invalidFuncCharacters.ReplaceAllString(progName, "_") + "_completion"
+
+	script := fmt.Sprintf("# bash completion for %s\n", progName)
+	script += fmt.Sprintf("%s() {\n", funcName)
+	script += "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n"
+	script += "\tlocal words=\"${COMP_WORDS[*]:1:COMP_CWORD-1} \"\n"
+	script += "\tlocal candidates\n"
+	script += "\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n"
+	script += fmt.Sprintf("\t\tcandidates=%s\n", shellQuote(strings.Join(config.commandNames(), " ")))
+	script += "\telse\n"
+	script += "\t\tcase \"$words\" in\n"
+	for _, entry := range config.completionEntries() {
+		patterns := make([]string, 0)
+		for _, word := range entry.words {
+			patterns = append(patterns, shellQuote(word+" "))
+		}
+		script += fmt.Sprintf("\t\t%s*) candidates=%s ;;\n", strings.Join(patterns, "*"), shellQuote(strings.Join(entry.candidates(), " ")))
+	}
+	script += "\t\t*) candidates='' ;;\n"
+	script += "\t\tesac\n"
+	script += "\tfi\n"
+	script += "\tCOMPREPLY=($(compgen -W \"$candidates\" -- \"$cur\"))\n"
+	script += "}\n"
+	script += fmt.Sprintf("complete -F %s %s\n", funcName, progName)
+	return script
+}
+
+// GenerateZshCompletion returns a zsh completion script for the CLI when it is
+// run as the program progName, e.g. by saving the script as "_progName" in a
+// directory of the fpath. The commands and arguments are completed along with
+// their help messages, and the options are completed with their help messages,
+// variable labels and choices. Required options are marked as required.
+func (config *Config) GenerateZshCompletion(progName string) string {
+	funcName := "_" + invalidFuncCharacters.ReplaceAllString(progName, "_")
+
+	script := fmt.Sprintf("#compdef %s\n\n", progName)
+	script += fmt.Sprintf("%s() {\n", funcName)
+	script += "\tif (( CURRENT == 2 )); then\n"
+	script += "\t\tlocal -a commands\n"
+	script += "\t\tcommands=(\n"
+	for _, helpCmd := range config.helpCmds() {
+		script += fmt.Sprintf("\t\t\t%s\n", shellQuote(zshEscape(helpCmd)+":Show the help"))
+	}
+	for _, command := range config.Commands {
+		for _, name := range command.names() {
+			script += fmt.Sprintf("\t\t\t%s\n", shellQuote(zshEscape(name)+":"+zshEscape(command.helpModel().summary(config.HelpSummaryLength))))
+		}
+	}
+	script += "\t\t)\n"
+	script += "\t\t_describe 'command' commands\n"
+	script += "\t\treturn\n"
+	script += "\tfi\n\n"
+	script += "\t# Complete the options and the arguments following the command,\n"
+	script += "\t# ignoring the words preceding the current word except options\n"
+	script += "\tlocal preceding=\"${words[2,CURRENT-1]} \"\n"
+	script += "\tlocal -a options\n"
+	script += "\toptions=(${(M)words[2,CURRENT-1]:#-*})\n"
+	script += "\twords=(\"${words[1]}\" $options \"${words[CURRENT]}\")\n"
+	script += "\tCURRENT=${#words}\n"
+	script += "\tcase \"$preceding\" in\n"
+	for _, entry := range config.completionEntries() {
+		patterns := make([]string, 0)
+		for _, word := range entry.words {
+			patterns = append(patterns, shellQuote(word+" "))
+		}
+		script += fmt.Sprintf("\t%s*)\n", strings.Join(patterns, "*"))
+		if specs := zshSpecs(entry); specs != "" {
+			script += fmt.Sprintf("\t\t_arguments%s\n", specs)
+		} else {
+			script += "\t\t_message 'no more arguments'\n"
+		}
+		script += "\t\t;;\n"
+	}
+	script += "\tesac\n"
+	script += "}\n\n"
+	script += fmt.Sprintf("%s \"$@\"\n", funcName)
+	return script
+}
+
+// zshSpecs returns the _arguments specs for the options of the entry,
+// followed by the spec for the labelled arguments, if there are any.
+func zshSpecs(entry completionEntry) string {
+	var specs string
+	for _, option := range entry.options {
+
+		// Options with both a short and long name exclude each other
+		names := make([]string, 0)
+		if option.Short != "" {
+			names = append(names, option.Short)
+		}
+		if option.Long != "" {
+			names = append(names, option.Long)
+		}
+		var exclusion string
+		if option.Repeatable {
+			exclusion = "*"
+		} else if len(names) > 1 {
+			exclusion = "(" + strings.Join(names, " ") + ")"
+		}
+
+		// The help message is the description, marked if the option is required
+		description := option.HelpMsg
+		if option.Required || (option.Variable != nil && option.Variable.Required) {
+			description = strings.TrimRight("(required) "+description, " ")
+		}
+		description = strings.NewReplacer(`[`, `\[`, `]`, `\]`).Replace(description)
+
+		// Options with a variable are followed by the variable label and its choices
+		for _, name := range names {
+			spec := exclusion + name
+			if option.Variable != nil && name == option.Long {
+				spec += "="
+			} else if option.Variable != nil {
+				spec += "+"
+			}
+			spec += "[" + description + "]"
+			if option.Variable != nil {
+				spec += ":" + zshEscape(option.Variable.Label) + ":"
+				switch {
+				case len(option.Variable.Choices) > 0:
+					spec += "(" + strings.Join(option.Variable.Choices, " ") + ")"
+				case option.Variable.Type == typePath:
+					spec += "_files"
+				}
+			}
+			specs += " " + shellQuote(spec)
+		}
+	}
+
+	// The labelled arguments are completed with their help messages
+	if len(entry.arguments) > 0 {
+		values := make([]string, 0)
+		for _, argument := range entry.arguments {
+			label := strings.ReplaceAll(zshEscape(argument.Label), " ", `\ `)
+			values = append(values, fmt.Sprintf(`%s\:"%s"`, label, strings.ReplaceAll(argument.HelpMsg, `"`, `\"`)))
+		}
+		specs += " " + shellQuote("*:argument:(("+strings.Join(values, " ")+"))")
+	}
+	return specs
+}
+
+// zshEscape escapes the colons in the string for a zsh completion spec
+func zshEscape(s string) string {
+	return strings.ReplaceAll(s, ":", `\:`)
+}
+
+// commandNames returns the sorted labels and aliases of the commands,
+// along with each of the help commands.
+func (config *Config) commandNames() []string {
+	names := config.helpCmds()
+	for _, command := range config.Commands {
+		names = append(names, command.names()...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionEntries returns the completion candidates following each command and
+// argument in the config, ordered with the most specific invocations first.
+func (config *Config) completionEntries() []completionEntry {
+	entries := make([]completionEntry, 0)
+	for _, command := range config.Commands {
+		for _, name := range command.names() {
+			entries = append(entries, argumentEntries([]string{name}, command.Arguments)...)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return len(entries[i].words) > len(entries[j].words)
+	})
+	return entries
+}
+
+// argumentEntries returns the completion candidates following the words, which
+// are the labelled arguments and the options of any argument with an empty
+// label, as well as the candidates following each of the arguments.
+func argumentEntries(words []string, arguments []Argument) []completionEntry {
+	entry := completionEntry{words: words}
+	entries := make([]completionEntry, 0)
+	for _, argument := range arguments {
+
+		// An argument with an empty label doesn't add any words
+		if argument.Label == "" {
+			if len(argument.Arguments) == 0 {
+				entry.options = append(entry.options, argument.Options...)
+				continue
+			}
+			children := argumentEntries(words, argument.Arguments)
+			entry.arguments = append(entry.arguments, children[0].arguments...)
+			entry.options = append(entry.options, children[0].options...)
+			entries = append(entries, children[1:]...)
+			continue
+		}
+		entry.arguments = append(entry.arguments, argument)
+
+		// Add the candidates following the argument label
+		argWords := append(append([]string{}, words...), argument.Label)
+		if len(argument.Arguments) > 0 {
+			entries = append(entries, argumentEntries(argWords, argument.Arguments)...)
+		} else {
+			entries = append(entries, completionEntry{words: argWords, options: argument.Options})
+		}
+	}
+	return append([]completionEntry{entry}, entries...)
+}
+
+// optionNames returns the long name of each option, or the
+// short name for an option without a long name.
+func optionNames(options []Option) []string {
+	names := make([]string, 0)
+	for _, option := range options {
+		if option.Long != "" {
+			names = append(names, option.Long)
+		} else {
+			names = append(names, option.Short)
+		}
+	}
+	return names
+}
+
+// shellQuote quotes the string in single quotes for a shell script
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const shellConfig = `
+commands:
+  - label: get
+    help: Get the things
+    aliases: [g]
+    arguments:
+      - label: all
+        help: Get all the things
+        execFunc: GetAll
+        options:
+          - label: number
+            short: -n
+            long: --number
+            help: The number of things
+            required: true
+            variable:
+              label: count
+          - label: quiet
+            long: --quiet
+      - label: one
+        execFunc: GetOne
+  - label: remote
+    arguments:
+      - label: add
+        arguments:
+          - label: origin
+            execFunc: Origin
+            options:
+              - label: url
+                long: --url
+                variable:
+                  label: url
+`
+
+func TestGenerateBashCompletion(t *testing.T) {
+	script := loadTestConfig(t, shellConfig).GenerateBashCompletion("my-cli")
+	for _, want := range []string{"_my_cli_completion() {", "complete -F _my_cli_completion my-cli", "'g get help remote'", "'all one'", "'--number --quiet'", "'add'", "'origin'", "'--url'"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected %q in the script\n%s", want, script)
+		}
+	}
+
+	// Complete the words with the script, if bash is available
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not available")
+	}
+	tests := []struct {
+		words string
+		want  string
+	}{
+		{"my-cli ''", "g get help remote"},
+		{"my-cli g", "g get"},
+		{"my-cli get ''", "all one"},
+		{"my-cli g a", "all"},
+		{"my-cli get all --", "--number --quiet"},
+		{"my-cli get all -n 5 --q", "--quiet"},
+		{"my-cli remote ''", "add"},
+		{"my-cli remote add ''", "origin"},
+		{"my-cli remote add origin ''", "--url"},
+		{"my-cli nothing ''", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.words, func(t *testing.T) {
+			cmd := exec.Command("bash", "-c", script+"COMP_WORDS=("+test.words+"); COMP_CWORD=$((${#COMP_WORDS[@]}-1)); _my_cli_completion; echo \"${COMPREPLY[*]}\"")
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("%v: %s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != test.want {
+				t.Errorf("completing %s = %q, want %q", test.words, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateZshCompletion(t *testing.T) {
+	script := loadTestConfig(t, shellConfig).GenerateZshCompletion("my-cli")
+	for _, want := range []string{
+		"#compdef my-cli\n",
+		"_my_cli() {",
+		"'help:Show the help'",
+		"'get:Get the things'",
+		"'g:Get the things'",
+		"'remote:add'",
+		`'*:argument:((all\:"Get all the things" one\:""))'`,
+		"'(-n --number)-n+[(required) The number of things]:count:'",
+		"'(-n --number)--number=[(required) The number of things]:count:'",
+		"'--quiet[]'",
+		"'--url=[]:url:'",
+		"'get '*'all '*)",
+		"'remote '*'add '*'origin '*)",
+		"_my_cli \"$@\"\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected %q in the script\n%s", want, script)
+		}
+	}
+	if _, err := exec.LookPath("zsh"); err == nil {
+		if out, err := exec.Command("zsh", "-n", "-c", script).CombinedOutput(); err != nil {
+			t.Errorf("invalid zsh script: %v: %s", err, out)
+		}
+	}
+}
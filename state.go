@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"sync"
+)
+
+// The key used to store the current directory in the State.
+const stateDirKey = "dir"
+
+// State is a mutable store of values which persists between the
+// commands of a CLI session. Executables can access the State
+// of the session using Flags.State, allowing a command such as
+// "cd" to change a value that other commands later observe.
+// It is safe for concurrent use.
+type State struct {
+	mutex  sync.RWMutex
+	values map[string]interface{}
+}
+
+// newState creates a new empty State
+func newState() *State {
+	return &State{
+		values: make(map[string]interface{}),
+	}
+}
+
+// Get returns the value stored in the State with the given key,
+// and whether the key exists in the State.
+func (state *State) Get(key string) (value interface{}, exists bool) {
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+	value, exists = state.values[key]
+	return value, exists
+}
+
+// GetString returns the string value stored in the State with the
+// given key. If the key doesn't exist in the State or the value is
+// not a string, ("", false) will be returned instead.
+func (state *State) GetString(key string) (value string, exists bool) {
+	raw, ok := state.Get(key)
+	if !ok {
+		return "", false
+	}
+	value, exists = raw.(string)
+	return value, exists
+}
+
+// Set stores the value in the State with the given key.
+func (state *State) Set(key string, value interface{}) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.values[key] = value
+}
+
+// Delete removes the value stored in the State with the given key.
+func (state *State) Delete(key string) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	delete(state.values, key)
+}
+
+// clear removes all the values stored in the State.
+func (state *State) clear() {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.values = make(map[string]interface{})
+}
+
+// Dir returns the current directory stored in the State.
+// If no directory has been set, an empty string is returned.
+func (state *State) Dir() string {
+	dir, _ := state.GetString(stateDirKey)
+	return dir
+}
+
+// SetDir sets the current directory stored in the State.
+func (state *State) SetDir(dir string) {
+	state.Set(stateDirKey, dir)
+}
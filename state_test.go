@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+const stateConfig = `
+commands:
+  - label: cd
+    arguments:
+      - label: ""
+        execFunc: Cd
+        freeForm: true
+  - label: pwd
+    arguments:
+      - label: ""
+        execFunc: Pwd
+`
+
+func TestStateAcrossCommands(t *testing.T) {
+	cd := func(flags Flags) []byte {
+		flags.State().SetDir(strings.Join(flags.Args(), " "))
+		return nil
+	}
+	pwd := func(flags Flags) []byte { return []byte(flags.State().Dir() + "\n") }
+	app, _ := newTestApp(t, stateConfig, map[string]func(Flags) []byte{"Cd": cd, "Pwd": pwd}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"pwd", "\n"},
+		{"cd /tmp", ""},
+		{"pwd", "/tmp\n"},
+		{"cd /var/log", ""},
+		{"pwd", "/var/log\n"},
+	}
+	for _, test := range tests {
+		if got := string(app.Execute(test.input)); got != test.want {
+			t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	state := newState()
+	if _, ok := state.Get("count"); ok {
+		t.Error("expected an empty state")
+	}
+	state.Set("count", 1)
+	state.Set("name", "web")
+	tests := []struct {
+		key    string
+		want   string
+		exists bool
+	}{
+		{"name", "web", true},
+		{"count", "", false},
+		{"missing", "", false},
+	}
+	for _, test := range tests {
+		if got, ok := state.GetString(test.key); got != test.want || ok != test.exists {
+			t.Errorf("GetString(%q) = %q, %t, want %q, %t", test.key, got, ok, test.want, test.exists)
+		}
+	}
+	state.Delete("name")
+	if _, ok := state.Get("name"); ok {
+		t.Error("expected the deleted key to be removed")
+	}
+	state.clear()
+	if _, ok := state.Get("count"); ok {
+		t.Error("expected the cleared state to be empty")
+	}
+}
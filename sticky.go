@@ -0,0 +1,41 @@
+package cli
+
+// stickyKey identifies a sticky option of an argument of a command
+type stickyKey struct {
+	command  string
+	argument string
+	option   string
+}
+
+// restoreSticky sets any sticky options which were omitted from the input
+// to the value they were last set to in the session. The remembered value
+// takes precedence over the value of any environment variable.
+func (app *App) restoreSticky(command Command, argument Argument, flags Flags) {
+	for _, option := range argument.Options {
+		meta := flags.mapping[option.Label]
+		if !option.Sticky || meta.negated || (meta.isset && meta.source != sourceEnv) {
+			continue
+		}
+		if last, ok := app.sticky[stickyKey{command.Label, argument.path, option.Label}]; ok {
+			last.source = sourceSticky
+			flags.mapping[option.Label] = last
+		}
+	}
+}
+
+// rememberSticky remembers the value of any sticky options set or negated in the input,
+// to be used when the options are omitted from subsequent inputs.
+func (app *App) rememberSticky(command Command, argument Argument, flags Flags) {
+	for _, option := range argument.Options {
+		meta := flags.mapping[option.Label]
+		if !option.Sticky || !(meta.isset || meta.negated) || meta.source == sourceSticky {
+			continue
+		}
+		app.sticky[stickyKey{command.Label, argument.path, option.Label}] = meta
+	}
+}
+
+// clearSticky forgets the values of all the sticky options
+func (app *App) clearSticky() {
+	app.sticky = make(map[stickyKey]flagMetadata)
+}
@@ -0,0 +1,55 @@
+package cli
+
+import "testing"
+
+const stickyConfig = `
+longValueSeparators: ["="]
+resetCmd: reset
+commands:
+  - label: show
+    arguments:
+      - label: users
+        execFunc: Users
+        options:
+          - label: format
+            long: --format
+            sticky: true
+            variable:
+              label: format
+              default: table
+      - label: groups
+        execFunc: Groups
+        options:
+          - label: format
+            long: --format
+            variable:
+              label: format
+              default: table
+`
+
+func TestSticky(t *testing.T) {
+	format := func(flags Flags) []byte { return []byte(flags.GetVarOrDefault("format") + "\n") }
+	app, _ := newTestApp(t, stickyConfig, map[string]func(Flags) []byte{"Users": format, "Groups": format}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"show users", "table\n"},
+		{"show users --format=json", "json\n"},
+		{"show users", "json\n"},
+		{"show users", "json\n"},
+		{"show groups", "table\n"},
+		{"show groups --format=yaml", "yaml\n"},
+		{"show users", "json\n"},
+		{"show groups", "table\n"},
+		{"show users --format=csv", "csv\n"},
+		{"show users", "csv\n"},
+		{"reset", ""},
+		{"show users", "table\n"},
+	}
+	for _, test := range tests {
+		if got := string(app.Execute(test.input)); got != test.want {
+			t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"bytes"
+	"sync"
+)
+
+// lineWriter is the streaming writer provided to executables, allowing
+// output to appear while a long running command is still executing, e.g.
+// tailing logs. The output is buffered until a complete line has been
+// written and then flushed to the CLI, so partial lines never appear.
+type lineWriter struct {
+	mutex  sync.Mutex
+	app    *App
+	buffer []byte
+}
+
+// newLineWriter creates a new lineWriter which writes to the App
+func newLineWriter(app *App) *lineWriter {
+	return &lineWriter{
+		app:    app,
+		buffer: make([]byte, 0),
+	}
+}
+
+// Write buffers the bytes, writing any complete lines to the CLI
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buffer = append(w.buffer, b...)
+	index := bytes.LastIndexByte(w.buffer, '\n')
+	if index == -1 {
+		return len(b), nil
+	}
+	if err := w.app.write(w.buffer[:index+1]); err != nil {
+		return 0, err
+	}
+	w.buffer = append(make([]byte, 0), w.buffer[index+1:]...)
+	return len(b), nil
+}
+
+// remaining returns any partial line which has been buffered but not written
+func (w *lineWriter) remaining() []byte {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	remaining := w.buffer
+	w.buffer = make([]byte, 0)
+	return remaining
+}
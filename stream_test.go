@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		seen   []string
+		want   string
+	}{
+		{"lines", []string{"one\n", "two\n", "three\n"}, []string{"one\n", "one\ntwo\n", "one\ntwo\nthree\n"}, "done\n"},
+		{"partial lines", []string{"on", "e\ntw", "o\n"}, []string{"", "one\n", "one\ntwo\n"}, "done\n"},
+		{"several lines", []string{"one\ntwo\nthr", "ee\n"}, []string{"one\ntwo\n", "one\ntwo\nthree\n"}, "done\n"},
+		{"unterminated line", []string{"one\n", "tw"}, []string{"one\n", "one\n"}, "twdone\n"},
+		{"no lines", nil, nil, "done\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out *bytes.Buffer
+			tail := func(flags Flags) []byte {
+				for i, s := range test.writes {
+					if _, err := flags.Stream().Write([]byte(s)); err != nil {
+						t.Fatal(err)
+					}
+					if out.String() != test.seen[i] {
+						t.Errorf("after writing %q, the output is %q, want %q", s, out.String(), test.seen[i])
+					}
+				}
+				return []byte("done\n")
+			}
+			var app *App
+			app, out = newTestApp(t, getConfig, map[string]func(Flags) []byte{"GetAll": tail}, "")
+			if got := string(app.Execute("get all")); got != test.want {
+				t.Errorf("Execute() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// errInterrupted is returned when the user interrupts the input with ctl-C
+var errInterrupted = errors.New("interrupted")
+
+// Keys read from a terminal in raw mode
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyTab       = '\t'
+	keyNewline   = '\n'
+	keyEnter     = '\r'
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// Escape sequences read from a terminal in raw mode
+const (
+	seqUp        = "[A"
+	seqUpVt100   = "OA"
+	seqDown      = "[B"
+	seqDownVt100 = "OB"
+	seqRight     = "[C"
+	seqEnd       = "[F"
+	seqEndAlt    = "[4~"
+	seqEndVt100  = "OF"
+)
+
+// Colours used to display suggestions, and the prompt and help if enabled
+const (
+	ansiGrey  = "\x1b[90m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// colorize wraps the string in the ANSI colour code if color is true
+func colorize(s, code string, color bool) string {
+	if !color || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// color returns whether the output should be in colour. This is only the
+// case if colour has been enabled and the output of the CLI is a terminal.
+func (app *App) color() bool {
+	return app.currentConfig().Color && isTerminal(app.out)
+}
+
+// ansiPattern matches the common ANSI CSI escape sequences, e.g. colours
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// stripANSI removes any ANSI CSI escape sequences from the bytes
+func stripANSI(b []byte) []byte {
+	return ansiPattern.ReplaceAll(b, []byte{})
+}
+
+// helpFormat returns the format of the help written to the output. The
+// help is wrapped to the configured width, otherwise the width of the
+// terminal, or 80 columns if the output is not a terminal, and sorted if
+// the config sorts the help.
+func (app *App) helpFormat() helpFormat {
+	format := helpFormat{color: app.color(), width: app.currentConfig().HelpWidth, sorted: app.currentConfig().SortHelp}
+	if format.width > 0 {
+		return format
+	}
+	format.width = 80
+	if file, ok := app.out.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		if width, _, err := term.GetSize(int(file.Fd())); err == nil && width > 0 {
+			format.width = width
+		}
+	}
+	return format
+}
+
+// rawMode returns whether the input should be read from the terminal in
+// raw mode. This is only the case if a feature requiring raw mode has
+// been enabled and both the input and output of the CLI are terminals.
+func (app *App) rawMode() bool {
+	if !app.currentConfig().AutoSuggest && !app.currentConfig().EnableCompletion && app.currentConfig().HistorySize <= 0 {
+		return false
+	}
+	return isTerminal(app.in) && isTerminal(app.out)
+}
+
+// isTerminal returns whether the stream is a terminal
+func isTerminal(stream interface{}) bool {
+	file, ok := stream.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+// readRaw reads a line of input from the terminal in raw mode
+func (app *App) readRaw() (str string, err error) {
+
+	// Put the terminal into raw mode, restoring it once the line is read
+	fd := int(app.in.(*os.File).Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return str, err
+	}
+	defer term.Restore(fd, state)
+
+	return app.editLine()
+}
+
+// editLine reads a line of input key by key. The line is edited and
+// displayed as the user types, along with any suggestion from the
+// history. The up and down arrow keys move through the history,
+// starting after the most recent input.
+func (app *App) editLine() (str string, err error) {
+	line := make([]rune, 0)
+	var previous rune
+	var draft []rune
+	history := app.History()
+	cursor := len(history)
+	for {
+
+		// Display the line with any suggestion
+		var suggestion string
+		if app.currentConfig().AutoSuggest {
+			suggestion = suggest(history, string(line))
+		}
+		if err := app.render(string(line), suggestion); err != nil {
+			return str, err
+		}
+
+		// Read the next key
+		r, _, err := app.reader.ReadRune()
+		if err != nil {
+			return str, err
+		}
+		last := previous
+		previous = r
+
+		switch r {
+
+		// Complete the line, listing the candidates if tab is pressed
+		// twice without the line being completed any further.
+		case keyTab:
+			if !app.currentConfig().EnableCompletion {
+				break
+			}
+			completed, candidates := app.currentConfig().complete(string(line))
+			if completed != string(line) {
+				line = []rune(completed)
+			} else if len(candidates) > 1 && last == keyTab {
+				if err := app.write([]byte("\r\n" + strings.Join(candidates, "  ") + "\r\n")); err != nil {
+					return str, err
+				}
+			}
+
+		// Submit the line, without the suggestion
+		case keyEnter, keyNewline:
+			if err := app.render(string(line), ""); err != nil {
+				return str, err
+			}
+			if err := app.write([]byte("\r\n")); err != nil {
+				return str, err
+			}
+			return string(line) + "\n", nil
+
+		// Interrupt the CLI
+		case keyCtrlC:
+			if err := app.write([]byte("\r\n")); err != nil {
+				return str, err
+			}
+			return str, errInterrupted
+
+		// End of input, only if the line is empty
+		case keyCtrlD:
+			if len(line) == 0 {
+				if err := app.write([]byte("\r\n")); err != nil {
+					return str, err
+				}
+				return str, io.EOF
+			}
+
+		// Remove the last character
+		case keyBackspace, keyDelete:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+
+		// Accept the suggestion on right arrow or end, or move
+		// through the history on up or down arrow
+		case keyEscape:
+			seq, err := app.readEscape()
+			if err != nil {
+				return str, err
+			}
+			switch seq {
+			case seqRight, seqEnd, seqEndAlt, seqEndVt100:
+				line = append(line, []rune(suggestion)...)
+
+			// Recall the previous input, keeping the line being
+			// typed so it can be restored with the down arrow.
+			case seqUp, seqUpVt100:
+				if cursor > 0 {
+					if cursor == len(history) {
+						draft = line
+					}
+					cursor--
+					line = []rune(history[cursor])
+				}
+
+			// Recall the next input, or the line being typed
+			case seqDown, seqDownVt100:
+				if cursor < len(history) {
+					cursor++
+					if cursor == len(history) {
+						line = draft
+					} else {
+						line = []rune(history[cursor])
+					}
+				}
+			}
+
+		// Add any other printable character to the line
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line, r)
+			}
+		}
+	}
+}
+
+// readEscape reads the remainder of an escape sequence, after the
+// escape character, e.g. "[C" for the right arrow key.
+func (app *App) readEscape() (seq string, err error) {
+
+	// Escape sequences start with either [ or O
+	r, _, err := app.reader.ReadRune()
+	if err != nil {
+		return seq, err
+	}
+	seq = string(r)
+	if r != '[' && r != 'O' {
+		return seq, nil
+	}
+
+	// Read until the final character of the sequence
+	for {
+		r, _, err = app.reader.ReadRune()
+		if err != nil {
+			return seq, err
+		}
+		seq += string(r)
+		if r >= 0x40 && r <= 0x7e {
+			return seq, nil
+		}
+	}
+}
+
+// render redraws the current line of input, followed by the suggestion in grey.
+// The cursor is placed at the end of the line, before the suggestion.
+func (app *App) render(line, suggestion string) error {
+	output := fmt.Sprintf("\r%s%s\x1b[K", app.prompt, line)
+	if suggestion != "" {
+		output += fmt.Sprintf("%s%s%s\x1b[%dD", ansiGrey, suggestion, ansiReset, utf8.RuneCountInString(suggestion))
+	}
+	return app.write([]byte(output))
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	up   = "\x1b[A"
+	down = "\x1b[B"
+)
+
+func TestEditLineHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"up", up + "\r", "get c\n"},
+		{"up twice", up + up + "\r", "get b\n"},
+		{"up past the oldest", up + up + up + up + "\r", "get a\n"},
+		{"up then down", up + up + down + "\r", "get c\n"},
+		{"down restores the draft", "ge" + up + down + "\r", "ge\n"},
+		{"down without history recall", down + "x\r", "x\n"},
+		{"vt100 up", "\x1bOA\r", "get c\n"},
+		{"edit recalled input", up + "\x7fb\r", "get b\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := NewWithIO(&Config{HistorySize: 10}, strings.NewReader(test.keys), io.Discard)
+			for _, input := range []string{"get a", "get b", "get c"} {
+				app.record(input)
+			}
+			got, err := app.editLine()
+			if err != nil || got != test.want {
+				t.Errorf("editLine() of %q = %q, %v, want %q", test.keys, got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestEditLineSuggestion(t *testing.T) {
+	tests := []struct {
+		name    string
+		suggest bool
+		keys    string
+		want    string
+	}{
+		{"accept with right arrow", true, "get a" + "\x1b[C" + "\r", "get all -n 5\n"},
+		{"accept with end", true, "get a" + "\x1b[F" + "\r", "get all -n 5\n"},
+		{"not accepted", true, "get a\r", "get a\n"},
+		{"disabled", false, "get a" + "\x1b[C" + "\r", "get a\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := new(strings.Builder)
+			app := NewWithIO(&Config{AutoSuggest: test.suggest}, strings.NewReader(test.keys), out)
+			app.record("get all -n 5")
+			got, err := app.editLine()
+			if err != nil || got != test.want {
+				t.Errorf("editLine() of %q = %q, %v, want %q", test.keys, got, err, test.want)
+			}
+			if shown := strings.Contains(out.String(), ansiGrey+"ll -n 5"); shown != test.suggest {
+				t.Errorf("suggestion shown %t, want %t in %q", shown, test.suggest, out.String())
+			}
+		})
+	}
+}
+
+func TestRawModeNotTerminal(t *testing.T) {
+	app := NewWithIO(&Config{AutoSuggest: true, EnableCompletion: true, HistorySize: 10}, strings.NewReader(""), io.Discard)
+	if app.rawMode() {
+		t.Error("expected raw mode to be disabled when the input and output are not terminals")
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name  string
+		strip bool
+		input string
+		want  string
+	}{
+		{"colour", true, "\x1b[31mred\x1b[0m\n", "red\n"},
+		{"bold and colour", true, "\x1b[1;32mok\x1b[0m done\n", "ok done\n"},
+		{"clear line", true, "\rline\x1b[K\n", "\rline\n"},
+		{"plain", true, "plain\n", "plain\n"},
+		{"disabled", false, "\x1b[31mred\x1b[0m\n", "\x1b[31mred\x1b[0m\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := new(strings.Builder)
+			app := NewWithIO(&Config{StripANSIWhenNotTTY: test.strip}, strings.NewReader(""), out)
+			if err := app.write([]byte(test.input)); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.want {
+				t.Errorf("write(%q) wrote %q to a non-tty writer, want %q", test.input, out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestColor(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tests := []struct {
+		name    string
+		enabled bool
+		out     io.Writer
+	}{
+		{"disabled", false, new(strings.Builder)},
+		{"buffer", true, new(strings.Builder)},
+		{"file", true, file},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := loadTestConfig(t, "prompt: \"> \"\ncolor: true\n"+getConfig)
+			config.Color = test.enabled
+			if err := config.WithFuncs(map[string]func(Flags) []byte{"Init": output(""), "Exit": output(""), "GetAll": output("all\n")}); err != nil {
+				t.Fatal(err)
+			}
+			app := NewWithIO(config, strings.NewReader("get help\nhelp\n"), test.out)
+			if app.color() {
+				t.Error("expected colour to be disabled when the output is not a terminal")
+			}
+			app.Run()
+			var written string
+			if b, ok := test.out.(*strings.Builder); ok {
+				written = b.String()
+			} else {
+				b, err := os.ReadFile(file.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				written = string(b)
+			}
+			if strings.Contains(written, "\x1b[") {
+				t.Errorf("Run() wrote escape codes to a writer which is not a terminal: %q", written)
+			}
+		})
+	}
+}
+
+func TestColorHelp(t *testing.T) {
+	config := loadTestConfig(t, getConfig)
+	command := config.Commands[0]
+	plain := command.helpModel().helpCmd(helpFormat{width: 80})
+	colored := command.helpModel().helpCmd(helpFormat{color: true, width: 80})
+	for _, want := range []string{"\t" + ansiCyan + "all" + ansiReset, "\t" + ansiCyan + "-q" + ansiReset} {
+		if !strings.Contains(colored, want) {
+			t.Errorf("expected %q in the coloured help %q", want, colored)
+		}
+	}
+	if strings.Contains(plain, "\x1b[") {
+		t.Errorf("expected no escape codes in the help %q", plain)
+	}
+	if got := stripANSI([]byte(colored)); string(got) != plain {
+		t.Errorf("expected the coloured help without the escape codes %q to match the help %q", got, plain)
+	}
+	if got, want := colorize("> ", ansiGreen, true), "\x1b[32m> \x1b[0m"; got != want {
+		t.Errorf("colorize() = %q, want %q", got, want)
+	}
+	if got := colorize("> ", ansiGreen, false); got != "> " {
+		t.Errorf("colorize() = %q, want the prompt unchanged", got)
+	}
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenSpan is a token of the input, which spans the input between the
+// start and end byte indexes, including any quotes.
+type tokenSpan struct {
+	text       string
+	start, end int
+}
+
+// tokenize splits the input into tokens separated by any of the delimiters,
+// e.g. whitespace. Text inside
+// single (') or double (") quotes is kept in a single token with the quotes
+// removed, so that values can contain whitespace, e.g. (-n "John Doe") or
+// (--name="John Doe"). Inside quotes, a backslash escapes the quote character
+// or another backslash. An error is returned if a quote is not terminated.
+func tokenize(input, delimiters string) (tokens []string, err error) {
+	spans, err := tokenizeSpans(input, delimiters)
+	tokens = make([]string, 0, len(spans))
+	for _, span := range spans {
+		tokens = append(tokens, span.text)
+	}
+	return tokens, err
+}
+
+// tokenizeSpans splits the input into tokens in the same way as tokenize,
+// along with where each token is in the input.
+func tokenizeSpans(input, delimiters string) (spans []tokenSpan, err error) {
+	spans = make([]tokenSpan, 0)
+	var token strings.Builder
+	var inToken bool
+	var start int
+	var quote rune
+
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRuneInString(input[i:])
+
+		// Inside quotes, everything up to the closing quote is part of the token
+		if quote != 0 {
+			switch {
+			case r == '\\' && i+1 < len(input) && (rune(input[i+1]) == quote || input[i+1] == '\\'):
+				token.WriteByte(input[i+1])
+				size++
+			case r == quote:
+				quote = 0
+			default:
+				token.WriteRune(r)
+			}
+			i += size
+			continue
+		}
+
+		switch {
+
+		// A delimiter ends the current token
+		case strings.ContainsRune(delimiters, r):
+			if inToken {
+				spans = append(spans, tokenSpan{text: token.String(), start: start, end: i})
+				token.Reset()
+				inToken = false
+			}
+
+		// Start of a quoted section of the token
+		case r == '"' || r == '\'':
+			if !inToken {
+				start = i
+			}
+			quote = r
+			inToken = true
+
+		default:
+			if !inToken {
+				start = i
+			}
+			token.WriteRune(r)
+			inToken = true
+		}
+		i += size
+	}
+
+	if quote != 0 {
+		return spans, fmt.Errorf("unterminated quote (%c) detected", quote)
+	}
+	if inToken {
+		spans = append(spans, tokenSpan{text: token.String(), start: start, end: len(input)})
+	}
+	return spans, nil
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		delimiters string
+		want       []string
+		wantErr    bool
+	}{
+		{"whitespace", "get  all\t-n 5", whitespaceCharacters, []string{"get", "all", "-n", "5"}, false},
+		{"commas", "get,all,,-n,5", ",", []string{"get", "all", "-n", "5"}, false},
+		{"commas keep spaces", "say,hello world", ",", []string{"say", "hello world"}, false},
+		{"tabs preserved", "say hello\tworld", " ", []string{"say", "hello\tworld"}, false},
+		{"spaces and commas", "get, all", " ,", []string{"get", "all"}, false},
+		{"quoted delimiter", `say,"a,b"`, ",", []string{"say", "a,b"}, false},
+		{"double quotes", `say "hello world"`, " ", []string{"say", "hello world"}, false},
+		{"single quotes", `say 'hello world'`, " ", []string{"say", "hello world"}, false},
+		{"escaped quote", `say "a \"b\" c"`, " ", []string{"say", `a "b" c`}, false},
+		{"other quote inside", `say '"a" b' "'c'"`, " ", []string{"say", `"a" b`, "'c'"}, false},
+		{"quoted part of a token", `--name="John Doe"`, " ", []string{"--name=John Doe"}, false},
+		{"empty quotes", `say ""`, " ", []string{"say", ""}, false},
+		{"unterminated quote", `say "a`, " ", []string{"say"}, true},
+		{"unterminated single quote", `say 'a b`, " ", []string{"say"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := tokenize(test.input, test.delimiters)
+			if (err != nil) != test.wantErr || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("tokenize(%q, %q) = %q, %v, want %q", test.input, test.delimiters, got, err, test.want)
+			}
+		})
+	}
+}
+
+const delimitersConfig = `
+delimiters: " ,"
+commands:
+  - label: get
+    arguments:
+      - label: all
+        execFunc: GetAll
+        options:
+          - label: number
+            short: -n
+            variable:
+              label: number
+`
+
+func TestDelimiters(t *testing.T) {
+	app, _ := newTestApp(t, delimitersConfig, map[string]func(Flags) []byte{"GetAll": echo("all")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"get all -n 5", "all {number: set=true value=\"5\"}\n"},
+		{"get,all,-n,5", "all {number: set=true value=\"5\"}\n"},
+		{",get, all ,-n 5,", "all {number: set=true value=\"5\"}\n"},
+		{"get\tall", "unable to find command \"get\tall\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDelimitersHelpSuffix(t *testing.T) {
+	tests := []struct {
+		delimiters string
+		label      string
+		wantErr    bool
+	}{
+		{"", "tasks help", true},
+		{"", "tasks\thelp", true},
+		{"", "tasks,help", false},
+		{" ,", "tasks,help", true},
+		{" ,", "taskshelp", false},
+	}
+	for _, test := range tests {
+		t.Run(test.delimiters+" "+test.label, func(t *testing.T) {
+			config := testHeader + "delimiters: \"" + test.delimiters + "\"\ncommands:\n  - label: show\n    arguments:\n      - label: \"" + strings.ReplaceAll(test.label, "\t", `\t`) + "\"\n"
+			_, err := LoadConfigBytes([]byte(config))
+			if (err != nil) != test.wantErr {
+				t.Errorf("LoadConfigBytes() with the argument %q = %v, want error %t", test.label, err, test.wantErr)
+			}
+		})
+	}
+}
+
+const quotedConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: greet
+    arguments:
+      - label: user
+        execFunc: User
+        options:
+          - label: name
+            short: -n
+            long: --name
+            variable:
+              label: name
+          - label: loud
+            short: -l
+`
+
+func TestQuotedValues(t *testing.T) {
+	app, _ := newTestApp(t, quotedConfig, map[string]func(Flags) []byte{"User": echo("user")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`greet user -n "John Doe"`, "{loud: set=false, name: set=true value=\"John Doe\"}"},
+		{`greet user -n 'John Doe'`, "{loud: set=false, name: set=true value=\"John Doe\"}"},
+		{`greet user --name="John Doe"`, "{loud: set=false, name: set=true value=\"John Doe\"}"},
+		{`greet user -n "John \"JD\" Doe"`, "{loud: set=false, name: set=true value=\"John \\\"JD\\\" Doe\"}"},
+		{`greet user -n "John Doe" -l`, "{loud: set=true, name: set=true value=\"John Doe\"}"},
+		{`greet user -l -n "John Doe"`, "{loud: set=true, name: set=true value=\"John Doe\"}"},
+		{`greet user -n "John Doe`, `unterminated quote (") detected`},
+		{`greet user -n 'John Doe`, `unterminated quote (') detected`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := parseTest(app, test.input); got != test.want {
+				t.Errorf("Parse(%q) = %s, want %s", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	tests := []struct {
+		name           string
+		delimiters     string
+		separator      string
+		wantDelimiters string
+		wantSeparator  string
+	}{
+		{"defaults", "", "", whitespaceCharacters, " "},
+		{"first delimiter", ",;", "", ",;", ","},
+		{"one of the delimiters", " ,", ",", " ,", ","},
+		{"added to the delimiters", " ", ";", "; ", ";"},
+		{"added to the whitespace", "", ";", ";" + whitespaceCharacters, ";"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &Config{Delimiters: test.delimiters, Separator: test.separator}
+			if got := config.delimiters(); got != test.wantDelimiters {
+				t.Errorf("delimiters() = %q, want %q", got, test.wantDelimiters)
+			}
+			if got := config.separator(); got != test.wantSeparator {
+				t.Errorf("separator() = %q, want %q", got, test.wantSeparator)
+			}
+		})
+	}
+}
+
+func TestSeparatorInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		input  string
+		want   string
+	}{
+		{"double spaces", "", "get  all  -n  5", "all {number: set=true value=\"5\"}\n"},
+		{"leading and trailing spaces", "", "  get all -n 5  ", "all {number: set=true value=\"5\"}\n"},
+		{"spaces and tabs", "", "get \t all\t\t-n 5", "all {number: set=true value=\"5\"}\n"},
+		{"custom separator", "separator: \";\"\n", "get;all;-n;5", "all {number: set=true value=\"5\"}\n"},
+		{"runs of the custom separator", "separator: \";\"\n", "get;;all;;;-n;5;", "all {number: set=true value=\"5\"}\n"},
+		{"custom separator and spaces", "separator: \";\"\n", "get; all ;-n 5", "all {number: set=true value=\"5\"}\n"},
+		{"custom separator only", "separator: \";\"\ndelimiters: \";\"\n", "get;all;-n;5 6", "all {number: set=true value=\"5 6\"}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := strings.Replace(delimitersConfig, "delimiters: \" ,\"\n", test.config, 1)
+			app, _ := newTestApp(t, config, map[string]func(Flags) []byte{"GetAll": echo("all")}, "")
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSeparatorJoinsLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"backslash at the end", "get;all\\\n-n;5\n"},
+		{"backslash before delimiters", "get;all\\;;\n-n;5\n"},
+	}
+	config := strings.Replace(delimitersConfig, "delimiters: \" ,\"\n", "separator: \";\"\ndelimiters: \";\"\nprompt: \"$ \"\n", 1)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, out := newTestApp(t, config, map[string]func(Flags) []byte{"GetAll": echo("all")}, test.input)
+			app.Run()
+			if want := "$ > all {number: set=true value=\"5\"}\n$ "; out.String() != want {
+				t.Errorf("Run() wrote %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestSeparatorInvalid(t *testing.T) {
+	tests := []struct {
+		separator string
+		want      string
+	}{
+		{";;", `invalid separator ";;", must be a single character`},
+		{"-", `invalid separator "-", dashes, quotes and backslashes cannot be delimiters`},
+		{`'`, `invalid separator "'", dashes, quotes and backslashes cannot be delimiters`},
+	}
+	for _, test := range tests {
+		t.Run(test.separator, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(testHeader + "separator: \"" + strings.ReplaceAll(test.separator, `"`, `\"`) + "\"\n" + getConfig))
+			if err == nil || err.Error() != test.want {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units which a variable can be converted from
+const (
+	unitBytes    = "bytes"
+	unitDuration = "duration"
+)
+
+// byteSuffixes are the accepted suffixes for the bytes unit, with their
+// multipliers. The suffixes are matched ignoring case, the longest first.
+var byteSuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"kib", 1 << 10},
+	{"mib", 1 << 20},
+	{"gib", 1 << 30},
+	{"tib", 1 << 40},
+	{"kb", 1 << 10},
+	{"mb", 1 << 20},
+	{"gb", 1 << 30},
+	{"tb", 1 << 40},
+	{"k", 1 << 10},
+	{"m", 1 << 20},
+	{"g", 1 << 30},
+	{"t", 1 << 40},
+	{"b", 1},
+}
+
+// convertUnit converts a human-friendly value in the given unit into its
+// canonical numeric value. For the bytes unit, e.g. "10MB", the value is
+// converted into a number of bytes. For the duration unit, e.g. "2m", the
+// value is converted into a number of nanoseconds. If the unit or the
+// value is empty, e.g. for an option given without a value, the value is
+// returned unchanged.
+func convertUnit(unit, value string) (string, error) {
+	if unit == "" || value == "" {
+		return value, nil
+	}
+	switch unit {
+	case unitBytes:
+		bytes, err := parseBytes(value)
+		if err != nil {
+			return value, err
+		}
+		return strconv.FormatInt(bytes, 10), nil
+	case unitDuration:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return value, fmt.Errorf("invalid duration \"%s\"", value)
+		}
+		return strconv.FormatInt(int64(duration), 10), nil
+	}
+	return value, fmt.Errorf("unknown unit \"%s\"", unit)
+}
+
+// parseBytes parses a size with an optional suffix, e.g. "10MB", into a
+// number of bytes. Suffixes use multiples of 1024.
+func parseBytes(value string) (int64, error) {
+	number, multiplier := value, 1.0
+	for _, s := range byteSuffixes {
+		if strings.HasSuffix(strings.ToLower(value), s.suffix) {
+			number, multiplier = value[:len(value)-len(s.suffix)], s.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size \"%s\"", value)
+	}
+	return int64(size * multiplier), nil
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		unit    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{unitBytes, "10MB", "10485760", false},
+		{unitBytes, "10mib", "10485760", false},
+		{unitBytes, "1.5k", "1536", false},
+		{unitBytes, "512", "512", false},
+		{unitBytes, "", "", false},
+		{unitBytes, "10XB", "", true},
+		{unitBytes, "-1KB", "", true},
+		{unitDuration, "2m", "120000000000", false},
+		{unitDuration, "1h30m", "5400000000000", false},
+		{unitDuration, "", "", false},
+		{unitDuration, "2 minutes", "", true},
+		{"", "10MB", "10MB", false},
+		{"furlongs", "10", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.unit+" "+test.value, func(t *testing.T) {
+			got, err := convertUnit(test.unit, test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("convertUnit(%q, %q) = %v, want error %t", test.unit, test.value, err, test.wantErr)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("convertUnit(%q, %q) = %q, want %q", test.unit, test.value, got, test.want)
+			}
+		})
+	}
+}
+
+const unitsConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: upload
+    arguments:
+      - label: ""
+        execFunc: Upload
+        options:
+          - label: size
+            long: --size
+            variable:
+              label: size
+              unit: bytes
+          - label: timeout
+            long: --timeout
+            variable:
+              label: timeout
+              unit: duration
+              default: 30s
+`
+
+func TestUnits(t *testing.T) {
+	app, _ := newTestApp(t, unitsConfig, map[string]func(Flags) []byte{"Upload": output("")}, "")
+	tests := []struct {
+		input   string
+		size    string
+		timeout time.Duration
+		err     string
+	}{
+		{"upload --size=10MB --timeout=2m", "10485760", 2 * time.Minute, ""},
+		{"upload --size=1k", "1024", 30 * time.Second, ""},
+		{"upload --size", "", 30 * time.Second, ""},
+		{"upload --size=10XB", "", 0, `option "size", invalid size "10XB"`},
+		{"upload --timeout=soon", "", 0, `option "timeout", invalid duration "soon"`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, _, flags, err := app.Parse(test.input)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Parse(%q) = %v, want %q", test.input, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) = %v", test.input, err)
+			}
+			if size := flags.GetVarOrDefault("size"); size != test.size {
+				t.Errorf("size = %q, want %q", size, test.size)
+			}
+			if timeout := flags.GetVarOrDefault("timeout"); timeout != strconv.FormatInt(int64(test.timeout), 10) {
+				t.Errorf("timeout = %q, want %s", timeout, test.timeout)
+			}
+			if size, err := flags.GetInt("size"); test.size != "" && (err != nil || strconv.Itoa(size) != test.size) {
+				t.Errorf("GetInt(size) = %d, %v, want %s", size, err, test.size)
+			}
+			if timeout, err := flags.GetDuration("timeout"); flags.IsSet("timeout") && (err != nil || timeout != test.timeout) {
+				t.Errorf("GetDuration(timeout) = %s, %v, want %s", timeout, err, test.timeout)
+			}
+		})
+	}
+}
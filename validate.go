@@ -18,6 +18,16 @@ func (cmd Command) validate() error {
 		return fmt.Errorf("invalid command label \"%s\", invalid whitespace characters detected", cmd.Label)
 	}
 
+	// Aliases must be non-empty strings without any whitespace characters
+	for _, alias := range cmd.Aliases {
+		if alias == "" {
+			return fmt.Errorf("command \"%s\", empty alias detected", cmd.Label)
+		}
+		if strings.ContainsAny(alias, " \n\r\t") {
+			return fmt.Errorf("command \"%s\", invalid alias \"%s\", invalid whitespace characters detected", cmd.Label, alias)
+		}
+	}
+
 	// There must be at least one argument
 	if len(cmd.Arguments) == 0 {
 		return fmt.Errorf("command \"%s\" requires at least one argument", cmd.Label)
@@ -39,6 +49,11 @@ func (cmd Command) validate() error {
 		labels[arg.Label] = true
 	}
 
+	// There must be at most one default argument
+	if err := validateDefault(cmd.Arguments); err != nil {
+		return fmt.Errorf("command \"%s\", %s", cmd.Label, err)
+	}
+
 	return nil
 }
 
@@ -92,6 +107,87 @@ func (arg Argument) validate() error {
 		}
 	}
 
+	// Options referenced by other options must exist
+	for _, opt := range arg.Options {
+		for _, label := range opt.Clears {
+			if _, exists := labels[label]; !exists {
+				return fmt.Errorf("argument \"%s\", option \"%s\" clears unknown option \"%s\"", arg.Label, opt.Label, label)
+			}
+		}
+		for _, label := range opt.Requires {
+			if _, exists := labels[label]; !exists {
+				return fmt.Errorf("argument \"%s\", option \"%s\" requires unknown option \"%s\"", arg.Label, opt.Label, label)
+			}
+		}
+	}
+
+	// Options in the exclusive groups must exist
+	for _, group := range arg.ExclusiveGroups {
+		for _, label := range group {
+			if _, exists := labels[label]; !exists {
+				return fmt.Errorf("argument \"%s\", exclusive group contains unknown option \"%s\"", arg.Label, label)
+			}
+		}
+	}
+
+	// An argument with child arguments is never invoked itself
+	if len(arg.Arguments) > 0 {
+		if len(arg.Options) > 0 {
+			return fmt.Errorf("argument \"%s\", options cannot be used with child arguments", arg.Label)
+		}
+		if arg.ExecFunc != "" {
+			return fmt.Errorf("argument \"%s\", execFunc cannot be used with child arguments", arg.Label)
+		}
+		if arg.Raw {
+			return fmt.Errorf("argument \"%s\", raw cannot be used with child arguments", arg.Label)
+		}
+		if arg.FreeForm {
+			return fmt.Errorf("argument \"%s\", freeForm cannot be used with child arguments", arg.Label)
+		}
+	}
+
+	// Child arguments must all be valid and do not repeat
+	childLabels := make(map[string]bool)
+	for _, child := range arg.Arguments {
+		if err := child.validate(); err != nil {
+			return fmt.Errorf("argument \"%s\", %s", arg.Label, err)
+		}
+		if _, alreadyExists := childLabels[child.Label]; alreadyExists {
+			return fmt.Errorf("argument \"%s\", multiple occurrences of the argument label \"%s\"", arg.Label, child.Label)
+		}
+		childLabels[child.Label] = true
+	}
+
+	// There must be at most one default child argument
+	if err := validateDefault(arg.Arguments); err != nil {
+		return fmt.Errorf("argument \"%s\", %s", arg.Label, err)
+	}
+
+	return nil
+}
+
+// validateDefault performs a validation check on the default argument of
+// the arguments. There must be at most one default argument, which cannot
+// be used alongside an argument with an empty label, as the argument with
+// the empty label already receives the input when no label is matched.
+func validateDefault(arguments []Argument) error {
+	var defaultLabel string
+	var hasDefault, hasEmpty bool
+	for _, arg := range arguments {
+		if arg.Label == "" {
+			hasEmpty = true
+		}
+		if !arg.Default {
+			continue
+		}
+		if hasDefault {
+			return fmt.Errorf("multiple default arguments \"%s\" and \"%s\" detected", defaultLabel, arg.Label)
+		}
+		defaultLabel, hasDefault = arg.Label, true
+	}
+	if hasDefault && hasEmpty && defaultLabel != "" {
+		return fmt.Errorf("default argument \"%s\" cannot be used with an empty argument label", defaultLabel)
+	}
 	return nil
 }
 
@@ -163,6 +259,11 @@ func (opt Option) validate() error {
 		}
 	}
 
+	// A key value option must have a variable
+	if opt.KeyValue && opt.Variable == nil {
+		return fmt.Errorf("option \"%s\", a key value option requires a variable", opt.Label)
+	}
+
 	// The variable must be valid
 	if opt.Variable != nil {
 		if err := opt.Variable.validate(); err != nil {
@@ -174,7 +275,7 @@ func (opt Option) validate() error {
 }
 
 // validate performs a validation check on an Argument
-func (va Variable) validate() error {
+func (va *Variable) validate() error {
 
 	// Label must be a non-empty string
 	if va.Label == "" {
@@ -186,5 +287,60 @@ func (va Variable) validate() error {
 		return fmt.Errorf("invalid variable label \"%s\", invalid whitespace characters detected", va.Label)
 	}
 
+	// Default must be one of the choices
+	if len(va.Choices) > 0 && va.Default != "" && !va.isChoice(va.Default) {
+		return fmt.Errorf("variable \"%s\", default \"%s\" is not one of the choices", va.Label, va.Default)
+	}
+
+	// Environment variable must be a valid name
+	if strings.ContainsAny(va.EnvVar, "= \n\r\t") {
+		return fmt.Errorf("variable \"%s\", invalid environment variable \"%s\"", va.Label, va.EnvVar)
+	}
+
+	// Type must be known
+	if va.Type != "" && va.Type != typePath {
+		return fmt.Errorf("variable \"%s\", unknown type \"%s\"", va.Label, va.Type)
+	}
+	if va.MustExist && va.Type != typePath {
+		return fmt.Errorf("variable \"%s\", must exist is only valid for the type \"%s\"", va.Label, typePath)
+	}
+
+	// Minimum and maximum lengths must be non-negative, the minimum must not
+	// exceed the maximum, and the default must be within the lengths
+	if va.MinLength < 0 || va.MaxLength < 0 {
+		return fmt.Errorf("variable \"%s\", lengths must not be negative", va.Label)
+	}
+	if va.MaxLength > 0 && va.MinLength > va.MaxLength {
+		return fmt.Errorf("variable \"%s\", minimum length %d exceeds the maximum length %d", va.Label, va.MinLength, va.MaxLength)
+	}
+	if va.Default != "" {
+		if err := va.checkLength(va.Default); err != nil {
+			return fmt.Errorf("variable \"%s\", invalid default, %s", va.Label, err)
+		}
+	}
+
+	// Pattern must be a valid regular expression, which is compiled once
+	// here, and the default must match the pattern
+	if va.Pattern != "" {
+		pattern, err := va.compilePattern()
+		if err != nil {
+			return fmt.Errorf("variable \"%s\", %s", va.Label, err)
+		}
+		va.pattern = pattern
+		if va.Default != "" && !pattern.MatchString(va.Default) {
+			return fmt.Errorf("variable \"%s\", default \"%s\" does not match the pattern \"%s\"", va.Label, va.Default, va.Pattern)
+		}
+	}
+
+	// Unit must be known, and the default must be valid for the unit
+	if va.Unit != "" && va.Unit != unitBytes && va.Unit != unitDuration {
+		return fmt.Errorf("variable \"%s\", unknown unit \"%s\"", va.Label, va.Unit)
+	}
+	if va.Unit != "" && va.Default != "" {
+		if _, err := convertUnit(va.Unit, va.Default); err != nil {
+			return fmt.Errorf("variable \"%s\", invalid default, %s", va.Label, err)
+		}
+	}
+
 	return nil
 }
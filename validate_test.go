@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDanglingReferences(t *testing.T) {
+	config := `
+commands:
+  - label: copy
+    arguments:
+      - label: files
+        %s
+        options:
+          - label: source
+            long: --source
+            %s
+          - label: dest
+            long: --dest
+          - label: force
+            short: -f
+`
+	tests := []struct {
+		name     string
+		argument string
+		option   string
+		want     string
+	}{
+		{"valid", "exclusiveGroups: [[dest, force]]", "requires: [dest]", ""},
+		{"clears", "", "clears: [dset]", `argument "files", option "source" clears unknown option "dset"`},
+		{"requires", "", "requires: [dest, forse]", `argument "files", option "source" requires unknown option "forse"`},
+		{"exclusive group", "exclusiveGroups: [[dest, frce]]", "", `argument "files", exclusive group contains unknown option "frce"`},
+		{"reference by long", "", "requires: [--dest]", `argument "files", option "source" requires unknown option "--dest"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(testHeader + fmt.Sprintf(config, test.argument, test.option)))
+			if (err == nil && test.want != "") || (err != nil && err.Error() != `command "copy", `+test.want) {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
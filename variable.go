@@ -1,5 +1,12 @@
 package cli
 
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"unicode/utf8"
+)
+
 // Variable is any set of consecutive characters or word
 // that follows an option.
 type Variable struct {
@@ -8,12 +15,126 @@ type Variable struct {
 	// a variable mapping when used in a action function.
 	// It will also be used as the variable placeholder in
 	// the help messages.
-	Label string `yaml:"label"`
+	Label string `yaml:"label" json:"label"`
 
 	// Whether the option for this variable is required
 	// for the command.
-	Required bool `yaml:"required"`
+	Required bool `yaml:"required,omitempty" json:"required,omitempty"`
 
 	// (optional) The default value for the variable
-	Default string `yaml:"default"`
+	Default string `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// (optional) the environment variable used for the value
+	// of the variable when no value is given in the input. The
+	// value given in the input takes precedence over the value
+	// of the environment variable, which takes precedence over
+	// the default. If the option is omitted from the input but
+	// the environment variable is set, the option is set.
+	EnvVar string `yaml:"envVar,omitempty" json:"envVar,omitempty"`
+
+	// (optional) the permitted values for the variable.
+	// If empty, any value is permitted.
+	Choices []string `yaml:"choices,omitempty" json:"choices,omitempty"`
+
+	// (optional) if true, the value for the variable is a
+	// reference to a JSON or YAML file, whose content can be
+	// unmarshaled using Flags.GetObject.
+	ObjectRef bool `yaml:"objectRef,omitempty" json:"objectRef,omitempty"`
+
+	// (optional) the unit of the variable, either "bytes" or
+	// "duration". Human-friendly values such as "10MB" or "2m"
+	// are converted into a number of bytes or nanoseconds,
+	// which can be retrieved using Flags.GetInt or
+	// Flags.GetDuration.
+	Unit string `yaml:"unit,omitempty" json:"unit,omitempty"`
+
+	// (optional) the type of the variable. The only type is
+	// "path", for a filesystem path which can be retrieved
+	// using Flags.GetPath.
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// (optional) if true, for a variable of type "path", the
+	// path must exist when the option is parsed.
+	MustExist bool `yaml:"mustExist,omitempty" json:"mustExist,omitempty"`
+
+	// (optional) the minimum number of characters in the
+	// value for the variable. If zero, there is no minimum.
+	MinLength int `yaml:"minLength,omitempty" json:"minLength,omitempty"`
+
+	// (optional) the maximum number of characters in the
+	// value for the variable. If zero, there is no maximum.
+	MaxLength int `yaml:"maxLength,omitempty" json:"maxLength,omitempty"`
+
+	// (optional) a regular expression which the whole value
+	// for the variable must match, e.g. "[a-z]+@[a-z.]+".
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+
+	// The compiled pattern, set when the variable is validated
+	pattern *regexp.Regexp
+}
+
+// isChoice returns whether the value is one of the choices for the variable.
+// If the variable has no choices, every value is a choice.
+func (va Variable) isChoice(value string) bool {
+	if len(va.Choices) == 0 {
+		return true
+	}
+	for _, choice := range va.Choices {
+		if choice == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLength returns an error if the number of characters in the value
+// is outside the minimum or maximum length for the variable, if any.
+func (va Variable) checkLength(value string) error {
+	length := utf8.RuneCountInString(value)
+	if va.MinLength > 0 && length < va.MinLength {
+		return fmt.Errorf("value \"%s\" is shorter than the minimum length %d", value, va.MinLength)
+	}
+	if va.MaxLength > 0 && length > va.MaxLength {
+		return fmt.Errorf("value \"%s\" is longer than the maximum length %d", value, va.MaxLength)
+	}
+	return nil
+}
+
+// compilePattern compiles the pattern for the variable, anchored so
+// that it must match the whole value.
+func (va Variable) compilePattern() (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(va.Pattern); err != nil {
+		return nil, fmt.Errorf("invalid pattern \"%s\", %s", va.Pattern, err)
+	}
+	return regexp.Compile("^(?:" + va.Pattern + ")$")
+}
+
+// checkPattern returns an error if the value doesn't match the pattern for
+// the variable, if any. The pattern is compiled if the variable has not
+// been validated.
+func (va Variable) checkPattern(value string) error {
+	if va.Pattern == "" {
+		return nil
+	}
+	pattern := va.pattern
+	if pattern == nil {
+		var err error
+		if pattern, err = va.compilePattern(); err != nil {
+			return err
+		}
+	}
+	if !pattern.MatchString(value) {
+		return fmt.Errorf("value \"%s\" does not match the pattern \"%s\"", value, va.Pattern)
+	}
+	return nil
+}
+
+// lookupEnv returns the value of the environment variable for the variable,
+// and whether the environment variable is configured and set to a value.
+func (va Variable) lookupEnv() (value string, ok bool) {
+	if va.EnvVar == "" {
+		return "", false
+	}
+	value = os.Getenv(va.EnvVar)
+	return value, value != ""
 }
@@ -0,0 +1,295 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const choicesConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: open
+    arguments:
+      - label: file
+        execFunc: File
+        options:
+          - label: mode
+            short: -m
+            long: --mode
+            variable:
+              label: mode
+              choices: [read, write, append]
+              default: read
+`
+
+func TestChoices(t *testing.T) {
+	mode := func(flags Flags) []byte { return []byte(flags.GetVarOrDefault("mode") + "\n") }
+	app, _ := newTestApp(t, choicesConfig, map[string]func(Flags) []byte{"File": mode}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"open file", "read\n"},
+		{"open file -m write", "write\n"},
+		{"open file --mode=append", "append\n"},
+		{"open file -m delete", "invalid value \"delete\" for option \"mode\", must be one of read, write, append\n"},
+		{"open file --mode=Read", "invalid value \"Read\" for option \"mode\", must be one of read, write, append\n"},
+		{"open file -m ''", "invalid value \"\" for option \"mode\", must be one of read, write, append\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestChoicesHelp(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"optional", choicesConfig, "\nUsage: open file\n\nopen file -m\n\t-m --mode (read|write|append)\n\n"},
+		{"required", strings.Replace(choicesConfig, "default: read", "required: true", 1), "\nUsage: open file\n\nopen file -m read|write|append\n\t-m --mode (read|write|append)\n\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, _ := newTestApp(t, test.config, map[string]func(Flags) []byte{"File": output("")}, "")
+			if got := string(app.Execute("open file help")); got != test.want {
+				t.Errorf("Execute(\"open file help\") = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestChoicesInvalidDefault(t *testing.T) {
+	_, err := LoadConfigBytes([]byte(testHeader + strings.Replace(choicesConfig, "default: read", "default: delete", 1)))
+	if want := `variable "mode", default "delete" is not one of the choices`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("LoadConfigBytes() = %v, want %q", err, want)
+	}
+}
+
+const envConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: deploy
+    arguments:
+      - label: app
+        execFunc: App
+        options:
+          - label: token
+            short: -t
+            long: --token
+            variable:
+              label: token
+              envVar: TEST_DEPLOY_TOKEN
+              default: none
+`
+
+func TestEnvVar(t *testing.T) {
+	app, _ := newTestApp(t, envConfig, map[string]func(Flags) []byte{"App": output("")}, "")
+	tests := []struct {
+		name    string
+		env     string
+		input   string
+		want    string
+		set     bool
+		orValue string
+	}{
+		{"explicit value over env", "secret", "deploy app -t given", "given", true, "given"},
+		{"explicit long value over env", "secret", "deploy app --token=given", "given", true, "given"},
+		{"set without value", "secret", "deploy app -t", "secret", true, "secret"},
+		{"long set without value", "secret", "deploy app --token", "secret", true, "secret"},
+		{"omitted", "secret", "deploy app", "secret", true, "secret"},
+		{"unset env omitted", "", "deploy app", "", false, "none"},
+		{"unset env set without value", "", "deploy app -t", "none", true, "none"},
+		{"unset env explicit value", "", "deploy app -t given", "given", true, "given"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("TEST_DEPLOY_TOKEN", test.env)
+			if test.env == "" {
+				os.Unsetenv("TEST_DEPLOY_TOKEN")
+			}
+			_, _, flags, err := app.Parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := flags.GetVar("token"); got != test.want {
+				t.Errorf("GetVar(token) = %q, want %q", got, test.want)
+			}
+			if set := flags.IsSet("token"); set != test.set {
+				t.Errorf("IsSet(token) = %t, want %t", set, test.set)
+			}
+			if got := flags.GetVarOrDefault("token"); got != test.orValue {
+				t.Errorf("GetVarOrDefault(token) = %q, want %q", got, test.orValue)
+			}
+		})
+	}
+}
+
+func TestEnvVarInvalid(t *testing.T) {
+	_, err := LoadConfigBytes([]byte(testHeader + strings.Replace(envConfig, "TEST_DEPLOY_TOKEN", "DEPLOY TOKEN", 1)))
+	if want := `variable "token", invalid environment variable "DEPLOY TOKEN"`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("LoadConfigBytes() = %v, want %q", err, want)
+	}
+}
+
+const lengthConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: add
+    arguments:
+      - label: user
+        execFunc: User
+        options:
+          - label: name
+            long: --name
+            variable:
+              label: name
+              minLength: 3
+              maxLength: 8
+          - label: team
+            long: --team
+            variable:
+              label: team
+              minLength: 2
+          - label: note
+            long: --note
+            variable:
+              label: note
+              maxLength: 4
+`
+
+func TestLength(t *testing.T) {
+	app, _ := newTestApp(t, lengthConfig, map[string]func(Flags) []byte{"User": output("added\n")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"add user", "added\n"},
+		{"add user --name=bob", "added\n"},
+		{"add user --name=12345678", "added\n"},
+		{"add user --name=jo", "option \"name\", value \"jo\" is shorter than the minimum length 3\n"},
+		{"add user --name=123456789", "option \"name\", value \"123456789\" is longer than the maximum length 8\n"},
+		{"add user --name=ééé", "added\n"},
+		{"add user --team=a", "option \"team\", value \"a\" is shorter than the minimum length 2\n"},
+		{"add user --team=unbounded-above", "added\n"},
+		{"add user --note=", "added\n"},
+		{"add user --note=hello", "option \"note\", value \"hello\" is longer than the maximum length 4\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+	help := string(app.Execute("add user help"))
+	for _, want := range []string{"(3-8 characters)", "(at least 2 characters)", "(at most 4 characters)"} {
+		if !strings.Contains(help, want) {
+			t.Errorf("expected %q in the help\n%s", want, help)
+		}
+	}
+}
+
+func TestLengthInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{"negative minimum", "minLength: 3", "minLength: -1", `variable "name", lengths must not be negative`},
+		{"negative maximum", "maxLength: 8", "maxLength: -8", `variable "name", lengths must not be negative`},
+		{"minimum exceeds maximum", "minLength: 3", "minLength: 9", `variable "name", minimum length 9 exceeds the maximum length 8`},
+		{"default too short", "minLength: 3", "minLength: 3\n              default: jo", `variable "name", invalid default, value "jo" is shorter than the minimum length 3`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(testHeader + strings.Replace(lengthConfig, test.old, test.new, 1)))
+			if err == nil || !strings.HasSuffix(err.Error(), test.want) {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+const patternConfig = `
+longValueSeparators: ["="]
+commands:
+  - label: invite
+    arguments:
+      - label: user
+        execFunc: User
+        options:
+          - label: email
+            long: --email
+            variable:
+              label: email
+              pattern: '[^@\s]+@[^@\s]+\.[a-z]+'
+          - label: id
+            long: --id
+            repeatable: true
+            variable:
+              label: id
+              pattern: 'u[0-9]+|admin'
+`
+
+func TestPattern(t *testing.T) {
+	app, _ := newTestApp(t, patternConfig, map[string]func(Flags) []byte{"User": output("invited\n")}, "")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"invite user", "invited\n"},
+		{"invite user --email=bob@example.com", "invited\n"},
+		{"invite user --email=bob", "option \"email\", value \"bob\" does not match the pattern \"[^@\\s]+@[^@\\s]+\\.[a-z]+\"\n"},
+		{"invite user --email=bob@example.com!", "option \"email\", value \"bob@example.com!\" does not match the pattern \"[^@\\s]+@[^@\\s]+\\.[a-z]+\"\n"},
+		{"invite user --id=u42 --id=admin", "invited\n"},
+		{"invite user --id=u42 --id=u42x", "option \"id\", value \"u42x\" does not match the pattern \"u[0-9]+|admin\"\n"},
+		{"invite user --id=xadmin", "option \"id\", value \"xadmin\" does not match the pattern \"u[0-9]+|admin\"\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if got := string(app.Execute(test.input)); got != test.want {
+				t.Errorf("Execute(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPatternInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{"invalid pattern", "pattern: 'u[0-9]+|admin'", "pattern: 'u[0-9'", `variable "id", invalid pattern "u[0-9", error parsing regexp: missing closing ]: ` + "`[0-9`"},
+		{"default not matching", "pattern: 'u[0-9]+|admin'", "pattern: 'u[0-9]+|admin'\n              default: root", `variable "id", default "root" does not match the pattern "u[0-9]+|admin"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfigBytes([]byte(testHeader + strings.Replace(patternConfig, test.old, test.new, 1)))
+			if err == nil || !strings.HasSuffix(err.Error(), test.want) {
+				t.Errorf("LoadConfigBytes() = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckPattern(t *testing.T) {
+	va := Variable{Label: "id", Pattern: "u[0-9]+"}
+	for value, want := range map[string]bool{"u1": true, "u123": true, "xu1": false, "u1x": false, "": false} {
+		if err := va.checkPattern(value); (err == nil) != want {
+			t.Errorf("checkPattern(%q) = %v, want match %t", value, err, want)
+		}
+	}
+	if err := (Variable{Pattern: "("}).checkPattern("x"); err == nil {
+		t.Error("expected an error checking an invalid pattern")
+	}
+}